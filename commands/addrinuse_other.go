@@ -0,0 +1,13 @@
+//go:build !windows
+
+package commands
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Report whether the error is caused by listening on an address that's already in use.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
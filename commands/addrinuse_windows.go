@@ -0,0 +1,13 @@
+package commands
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// Report whether the error is caused by listening on an address that's already in use.
+// Windows reports it with a winsock error code instead of EADDRINUSE.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, windows.WSAEADDRINUSE)
+}
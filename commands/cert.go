@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+// How long the generated certificates are valid for. They are replaced some time before
+// expiring, or when they don't cover the current server addresses.
+const CERT_VALIDITY = 365 * 24 * time.Hour
+const CERT_RENEW_BEFORE = 7 * 24 * time.Hour
+
+// Return a self-signed certificate for the given server host, the loopback addresses and the local
+// network ones, so the dev server can be accessed over https from other devices.
+// The certificate is cached in the user cache dir and reused while it's valid for all of those names.
+func localCertificate(host string) (tls.Certificate, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cacheDir = filepath.Join(cacheDir, "jorge")
+	certPath := filepath.Join(cacheDir, "cert.pem")
+	keyPath := filepath.Join(cacheDir, "key.pem")
+
+	names := certificateNames(host)
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err == nil && certificateCovers(leaf, names) {
+			return cert, nil
+		}
+	}
+
+	fmt.Printf("generating a self-signed certificate at %s\n", certPath)
+	certPEM, keyPEM, err := generateCertificate(names)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(cacheDir, DIR_RWE_MODE); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(certPath, certPEM, FILE_RW_MODE); err != nil {
+		return tls.Certificate{}, err
+	}
+	// the key is only readable by the current user
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// Return the host names and ip addresses the server can be reached at.
+func certificateNames(host string) []string {
+	names := []string{"localhost", "127.0.0.1", "::1"}
+	if ip := net.ParseIP(host); host != "" && !slices.Contains(names, host) && (ip == nil || !ip.IsUnspecified()) {
+		names = append(names, host)
+	}
+	return append(names, config.LocalAddresses()...)
+}
+
+// Report whether the certificate is valid for all the given names, and not about to expire.
+func certificateCovers(cert *x509.Certificate, names []string) bool {
+	if time.Now().Add(CERT_RENEW_BEFORE).After(cert.NotAfter) {
+		return false
+	}
+	for _, name := range names {
+		if cert.VerifyHostname(name) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Create a self-signed certificate for the given names, returning it and its private key PEM encoded.
+func generateCertificate(names []string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"jorge development server"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(CERT_VALIDITY),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGenerateCertificate(t *testing.T) {
+	names := []string{"localhost", "127.0.0.1", "::1", "mymachine.local", "192.168.0.10"}
+	certPEM, keyPEM, err := generateCertificate(names)
+	assertEqual(t, err, nil)
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assertEqual(t, err, nil)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assertEqual(t, err, nil)
+
+	assert(t, slices.Equal(leaf.DNSNames, []string{"localhost", "mymachine.local"}))
+	assertEqual(t, len(leaf.IPAddresses), 3)
+	assert(t, leaf.IPAddresses[2].Equal(net.ParseIP("192.168.0.10")))
+	assert(t, slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageServerAuth))
+	assert(t, leaf.NotBefore.Before(time.Now()))
+	assert(t, leaf.NotAfter.After(time.Now().Add(CERT_VALIDITY-time.Hour)))
+
+	// the certificate is self-signed
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "mymachine.local", Roots: pool})
+	assertEqual(t, err, nil)
+}
+
+func TestCertificateCovers(t *testing.T) {
+	certPEM, keyPEM, err := generateCertificate([]string{"localhost", "127.0.0.1", "192.168.0.10"})
+	assertEqual(t, err, nil)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assertEqual(t, err, nil)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assertEqual(t, err, nil)
+
+	assert(t, certificateCovers(leaf, []string{"localhost", "127.0.0.1", "192.168.0.10"}))
+	assert(t, certificateCovers(leaf, []string{"127.0.0.1"}))
+	assert(t, certificateCovers(leaf, nil))
+	// a new network address requires a new certificate
+	assert(t, !certificateCovers(leaf, []string{"localhost", "192.168.0.11"}))
+	assert(t, !certificateCovers(leaf, []string{"mymachine.local"}))
+
+	// certificates about to expire are replaced
+	leaf.NotAfter = time.Now().Add(CERT_RENEW_BEFORE - time.Hour)
+	assert(t, !certificateCovers(leaf, []string{"localhost"}))
+	leaf.NotAfter = time.Now().Add(CERT_RENEW_BEFORE + time.Hour)
+	assert(t, certificateCovers(leaf, []string{"localhost"}))
+}
+
+func TestCertificateNames(t *testing.T) {
+	names := certificateNames("0.0.0.0")
+	assert(t, slices.Contains(names, "localhost"))
+	assert(t, !slices.Contains(names, "0.0.0.0"))
+
+	names = certificateNames("mymachine.local")
+	assert(t, slices.Contains(names, "mymachine.local"))
+	assertEqual(t, slices.Index(names, "localhost"), 0)
+	// the default names aren't repeated
+	names = certificateNames("localhost")
+	assert(t, !slices.Contains(names[1:], "localhost"))
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -15,23 +16,80 @@ import (
 const FILE_RW_MODE = 0666
 const DIR_RWE_MODE = 0777
 
+// Flags to override the project directories, relative to the working directory.
+type DirFlags struct {
+	Source   string `help:"Directory with the site source files."`
+	Output   string `short:"o" help:"Directory to write the built site to."`
+	Layouts  string `help:"Directory with the layout templates."`
+	Includes string `help:"Directory with the include templates."`
+	Data     string `help:"Directory with the data files."`
+}
+
+// Set the directories passed as flags in the given config.
+func (flags DirFlags) apply(config *config.Config) {
+	dirs := []struct {
+		flag   string
+		target *string
+	}{
+		{flags.Source, &config.SrcDir},
+		{flags.Output, &config.TargetDir},
+		{flags.Layouts, &config.LayoutsDir},
+		{flags.Includes, &config.IncludesDir},
+		{flags.Data, &config.DataDir},
+	}
+	for _, dir := range dirs {
+		if dir.flag != "" {
+			*dir.target = filepath.Clean(dir.flag)
+		}
+	}
+}
+
 type Build struct {
-	ProjectDir string `arg:"" name:"path" optional:"" default:"." help:"Path to the website project to build."`
-	NoMinify   bool   `help:"Disable file minifying."`
+	ProjectDir    string `arg:"" name:"path" optional:"" default:"." help:"Path to the website project to build."`
+	Env           string `help:"Environment to build for, selects the config.<env>.yml overlay." env:"JORGE_ENV" default:"production"`
+	NoMinify      bool   `help:"Disable file minifying."`
+	Report        string `help:"Write a JSON report of the built files to the given path."`
+	KeepGoing     bool   `help:"Don't fail the build if some files fail to render."`
+	FailOnWarning bool   `help:"Fail the build if there are warnings, such as missing layouts or includes."`
+	DryRun        bool   `help:"Render the site in memory and list the changes to the target directory, without writing them."`
+	Diff          bool   `help:"Same as --dry-run, also printing unified diffs of the modified HTML and XML files."`
+	DirFlags      `embed:""`
 }
 
 // Read the files in src/ render them and copy the result to target/
 func (cmd *Build) Run(ctx *kong.Context) error {
 	start := time.Now()
 
-	config, err := config.Load(cmd.ProjectDir)
+	config, err := config.Load(cmd.ProjectDir, cmd.Env)
 	if err != nil {
 		return err
 	}
-	config.Minify = !cmd.NoMinify
+	if cmd.NoMinify {
+		config.Minify = false
+	}
+	cmd.DirFlags.apply(config)
+	config.KeepGoing = cmd.KeepGoing
+	config.FailOnWarning = cmd.FailOnWarning
 
-	err = site.Build(*config)
+	var report *site.BuildReport
+	if cmd.DryRun || cmd.Diff {
+		var changes *site.Changes
+		report, changes, err = site.DryRun(*config)
+		if err == nil && cmd.Diff {
+			err = changes.PrintDiffs(os.Stdout)
+		}
+		if changes != nil {
+			changes.PrintSummary(os.Stdout)
+		}
+	} else {
+		report, err = site.BuildWithReport(*config)
+	}
 	fmt.Printf("done in %.2fs\n", time.Since(start).Seconds())
+	if report != nil && cmd.Report != "" {
+		if reportErr := report.WriteJSON(cmd.Report); reportErr != nil {
+			return reportErr
+		}
+	}
 	return err
 }
 
@@ -52,15 +110,19 @@ func Prompt(label string) string {
 
 type Meta struct {
 	Expression string `arg:"" name:"expression" default:"site" help:"liquid expression to be evaluated (what goes inside of {{ ... }} in templates)"`
+	ProjectDir string `arg:"" name:"path" optional:"" default:"." help:"Path to the website project."`
+	Env        string `help:"Environment to load the site for, selects the config.<env>.yml overlay." env:"JORGE_ENV" default:"production"`
+	DirFlags   `embed:""`
 }
 
 // Load the site metadata and use it as context to evaluate a liquid expression
 func (cmd *Meta) Run(ctx *kong.Context) error {
 
-	config, err := config.Load(".")
+	config, err := config.Load(cmd.ProjectDir, cmd.Env)
 	if err != nil {
 		return err
 	}
+	cmd.DirFlags.apply(config)
 
 	// remove optional {{}} wrapper
 	expression := strings.Trim(cmd.Expression, " {}")
@@ -71,3 +133,23 @@ func (cmd *Meta) Run(ctx *kong.Context) error {
 	}
 	return err
 }
+
+type Config struct {
+	ProjectDir string `arg:"" name:"path" optional:"" default:"." help:"Path to the website project."`
+	Env        string `help:"Environment to print the configuration for, selects the config.<env>.yml overlay." env:"JORGE_ENV" default:"production"`
+}
+
+// Print the site configuration as it results from the defaults, the project config files
+// and the environment variables
+func (cmd *Config) Run(ctx *kong.Context) error {
+	config, err := config.Load(cmd.ProjectDir, cmd.Env)
+	if err != nil {
+		return err
+	}
+
+	output, err := config.AsYAML()
+	if err == nil {
+		fmt.Print(string(output))
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/facundoolano/jorge/config"
+	"github.com/facundoolano/jorge/deploy"
+	"github.com/facundoolano/jorge/site"
+)
+
+type Deploy struct {
+	ProjectDir string `arg:"" name:"path" optional:"" default:"." help:"Path to the website project to deploy."`
+	Target     string `short:"t" help:"Name of the deploy target declared in config.yml. Defaults to the first one."`
+	Env        string `help:"Environment to build and deploy, selects the config.<env>.yml overlay." env:"JORGE_ENV" default:"production"`
+	NoBuild    bool   `help:"Deploy the current contents of the target directory, without building the site first."`
+	Delete     bool   `help:"Delete the files at the destination that are no longer part of the site."`
+}
+
+// Build the site and publish the changed files to one of the deploy targets in config.yml.
+func (cmd *Deploy) Run(ctx *kong.Context) error {
+	start := time.Now()
+
+	config, err := config.Load(cmd.ProjectDir, cmd.Env)
+	if err != nil {
+		return err
+	}
+	settings, err := config.DeployTarget(cmd.Target)
+	if err != nil {
+		return err
+	}
+	if cmd.Delete {
+		settings.Delete = true
+	}
+	target, err := deploy.New(*config, *settings)
+	if err != nil {
+		return err
+	}
+
+	if !cmd.NoBuild {
+		if err := site.Build(*config); err != nil {
+			return err
+		}
+	} else if _, err := os.Stat(config.TargetDir); err != nil {
+		return fmt.Errorf("nothing to deploy, %s not found", config.TargetDir)
+	}
+
+	fmt.Printf("deploying %s to %s\n", config.TargetDir, settings.Name)
+	result, err := target.Deploy(config.TargetDir)
+	if err != nil {
+		return err
+	}
+	result.Print(os.Stdout)
+	fmt.Printf("done in %.2fs\n", time.Since(start).Seconds())
+	return nil
+}
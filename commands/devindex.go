@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/facundoolano/jorge/site"
+)
+
+// How many of the most recently changed pages to list in the dev index.
+const RECENT_PAGES_LIMIT = 15
+
+var devIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} · jorge</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: .2em .5em .2em 0; vertical-align: top; }
+.meta { color: #666; font-size: .9em; white-space: nowrap; }
+</style>
+</head>
+<body>
+<h1><a href="/">{{.Name}}</a></h1>
+<form method="post" action="/_jorge/drafts">
+Drafts are {{if .Drafts}}included in{{else}}excluded from{{end}} the site.
+<button type="submit">{{if .Drafts}}Exclude{{else}}Include{{end}} drafts</button>
+</form>
+{{define "pages"}}{{if .}}<table>
+{{range .}}<tr><td><a href="{{.Url}}">{{or .Title .Url}}</a></td><td class="meta">{{.Source}}</td><td class="meta">{{if .Date.IsZero}}{{else}}{{.Date.Format "2006-01-02"}}{{end}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}{{end}}
+<h2>Drafts</h2>
+{{template "pages" .DraftPages}}
+<h2>Scheduled posts</h2>
+{{template "pages" .ScheduledPages}}
+<h2>Recently changed</h2>
+{{template "pages" .RecentPages}}
+</body>
+</html>
+`))
+
+// Serve a page listing the site drafts, the posts dated in the future and the recently
+// changed pages, with a button to toggle the drafts.
+func (server *siteServer) serveDevIndex(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/_jorge/" {
+		http.NotFound(res, req)
+		return
+	}
+	config := server.handler.config.Load()
+	if config == nil {
+		http.Error(res, "the site is not built yet", http.StatusServiceUnavailable)
+		return
+	}
+	pages, err := server.handler.listPages()
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the list is shared by the requests until the next build
+	pages = slices.Clone(pages)
+
+	data := struct {
+		Name           string
+		Drafts         bool
+		DraftPages     []site.PageInfo
+		ScheduledPages []site.PageInfo
+		RecentPages    []site.PageInfo
+	}{Drafts: server.includeDrafts()}
+	data.Name, _ = config.AsContext()["name"].(string)
+	if data.Name == "" {
+		data.Name = config.SiteUrl
+	}
+
+	now := time.Now()
+	for _, page := range pages {
+		if page.Draft {
+			data.DraftPages = append(data.DraftPages, page)
+		}
+		if page.Date.After(now) {
+			data.ScheduledPages = append(data.ScheduledPages, page)
+		}
+	}
+	slices.SortStableFunc(pages, func(a site.PageInfo, b site.PageInfo) int {
+		return b.Modified.Compare(a.Modified)
+	})
+	data.RecentPages = pages[:min(len(pages), RECENT_PAGES_LIMIT)]
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := devIndexTemplate.Execute(res, data); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Switch whether the site includes drafts and rebuild it, redirecting back to the dev index.
+func (server *siteServer) toggleDrafts(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		res.Header().Set("Allow", http.MethodPost)
+		http.Error(res, "expected a POST request", http.StatusMethodNotAllowed)
+		return
+	}
+	if server.handler.config.Load() == nil {
+		http.Error(res, "the site is not built yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	drafts := !server.includeDrafts()
+	server.drafts.Store(&drafts)
+	server.reload()
+	http.Redirect(res, req, "/_jorge/", http.StatusSeeOther)
+}
+
+// Report whether drafts are included, either by the last toggle or flag, or by the config.
+func (server *siteServer) includeDrafts() bool {
+	if drafts := server.drafts.Load(); drafts != nil {
+		return *drafts
+	}
+	return server.handler.config.Load().IncludeDrafts
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDevIndex(t *testing.T) {
+	project := newProject()
+	defer os.RemoveAll(project.RootDir)
+	project.IncludeDrafts = true
+	newFile(project.SrcDir, "about.html", "---\ntitle: About me\n---\n<p>about</p>")
+	newFile(project.SrcDir, "blog/wip.md", "---\ntitle: Work in progress\ndate: 2024-01-01\ndraft: true\n---\nwip")
+	newFile(project.SrcDir, "blog/later.md", "---\ntitle: Coming soon\ndate: 2999-01-01\n---\nlater")
+
+	reloads := 0
+	server := &siteServer{handler: &siteHandler{}, reload: func() { reloads++ }}
+	response := serveRequest(http.HandlerFunc(server.serveDevIndex), "/_jorge/")
+	assertEqual(t, response.Code, http.StatusServiceUnavailable)
+
+	_, err := server.handler.rebuild(project)
+	assertEqual(t, err, nil)
+	response = serveRequest(http.HandlerFunc(server.serveDevIndex), "/_jorge/")
+	assertEqual(t, response.Code, http.StatusOK)
+	body := response.Body.String()
+	_, scheduled, _ := strings.Cut(body, "<h2>Scheduled posts</h2>")
+	drafts, _, _ := strings.Cut(body, "<h2>Scheduled posts</h2>")
+	assert(t, strings.Contains(drafts, `<a href="/blog/wip">Work in progress</a>`))
+	assert(t, !strings.Contains(drafts, "Coming soon"))
+	assert(t, strings.Contains(scheduled, `<a href="/blog/later">Coming soon</a>`))
+	assert(t, strings.Contains(body, `<a href="/about">About me</a>`))
+	assert(t, strings.Contains(body, "Exclude drafts"))
+
+	// the page list is reused until the next build
+	pages, _ := server.handler.listPages()
+	otherPages, _ := server.handler.listPages()
+	assertEqual(t, &pages[0], &otherPages[0])
+	server.handler.rebuild(project)
+	otherPages, _ = server.handler.listPages()
+	assert(t, &pages[0] != &otherPages[0])
+
+	response = serveRequest(http.HandlerFunc(server.toggleDrafts), "/_jorge/drafts")
+	assertEqual(t, response.Code, http.StatusMethodNotAllowed)
+	assertEqual(t, reloads, 0)
+
+	response = httptest.NewRecorder()
+	server.toggleDrafts(response, httptest.NewRequest(http.MethodPost, "/_jorge/drafts", nil))
+	assertEqual(t, response.Code, http.StatusSeeOther)
+	assertEqual(t, response.Header().Get("Location"), "/_jorge/")
+	assertEqual(t, reloads, 1)
+	assertEqual(t, server.includeDrafts(), false)
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"github.com/alecthomas/kong"
+	"github.com/facundoolano/jorge/config"
+	"github.com/facundoolano/jorge/site"
+)
+
+type Export struct {
+	Epub ExportEpub `cmd:"" help:"Export a collection of posts as an EPUB ebook."`
+}
+
+type ExportEpub struct {
+	ProjectDir string `arg:"" name:"path" optional:"" default:"." help:"Path to the website project to export."`
+	Output     string `short:"o" default:"book.epub" help:"Path of the resulting epub file."`
+	Tag        string `help:"Only include posts with the given tag."`
+	Series     string `help:"Only include posts with the given series front matter value."`
+	Dir        string `help:"Only include posts within the given directory of src."`
+	Env        string `help:"Environment to render the ebook for, selects the config.<env>.yml overlay." env:"JORGE_ENV" default:"production"`
+}
+
+// Render the selected site posts and pack them as an epub file.
+func (cmd *ExportEpub) Run(ctx *kong.Context) error {
+	config, err := config.Load(cmd.ProjectDir, cmd.Env)
+	if err != nil {
+		return err
+	}
+	selector := site.PostSelector{Tag: cmd.Tag, Series: cmd.Series, Dir: cmd.Dir}
+	return site.ExportEpub(*config, selector, cmd.Output)
+}
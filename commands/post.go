@@ -29,6 +29,7 @@ var DEFAULT_ORG_DIRECTIVES string = `#+OPTIONS: toc:nil num:nil
 
 type Post struct {
 	Title string `arg:"" optional:"" help:"Title of the post"`
+	Env   string `help:"Environment to load the configuration for, its config.<env>.yml overlay can change the post format and language." env:"JORGE_ENV" default:"production"`
 }
 
 // Create a new post template in the given site, with the given title,
@@ -38,7 +39,7 @@ func (cmd *Post) Run(ctx *kong.Context) error {
 	if title == "" {
 		title = Prompt("title")
 	}
-	config, err := config.Load(".")
+	config, err := config.Load(".", cmd.Env)
 	if err != nil {
 		return err
 	}
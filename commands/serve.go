@@ -1,30 +1,144 @@
 package commands
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/facundoolano/jorge/config"
+	"github.com/facundoolano/jorge/markup"
 	"github.com/facundoolano/jorge/site"
 	"github.com/fsnotify/fsnotify"
 )
 
+// The server-sent events published to live reload clients after a rebuild:
+// CSS_RELOAD_EVENT when only stylesheets changed, so they can be swapped in place;
+// PAGE_RELOAD_EVENT when other src files changed, to reload keeping the scroll position;
+// FULL_RELOAD_EVENT when the change may affect the whole site (e.g. layouts or data files).
+// BUILD_ERROR_EVENT is not named "error", to be distinguishable from EventSource connection errors.
+const CSS_RELOAD_EVENT = "css"
+const PAGE_RELOAD_EVENT = "page"
+const FULL_RELOAD_EVENT = "full"
+const BUILD_ERROR_EVENT = "build-error"
+
+// How long to wait for pending requests when shutting down the server.
+const SHUTDOWN_TIMEOUT = 5 * time.Second
+
+// How many ports to try after the configured one, when it's in use and --port auto is passed.
+const MAX_AUTO_PORTS = 100
+
+// An event sent to the live reload clients, with an optional payload to be encoded as JSON.
+type ServerEvent struct {
+	Type string
+	Data interface{}
+}
+
 type Serve struct {
-	ProjectDir string `arg:"" name:"path" optional:"" default:"." help:"Path to the website project to serve."`
-	Host       string `short:"H" default:"localhost" help:"Host to run the server on."`
-	Port       int    `short:"p" default:"4001" help:"Port to run the server on."`
-	NoReload   bool   `help:"Disable live reloading."`
+	ProjectDirs []string `arg:"" name:"path" optional:"" default:"." help:"Paths to the website projects to serve. Each project is served on its own port."`
+	Host        string   `short:"H" help:"Host to run the server on. Defaults to the server_host from config.yml, or localhost."`
+	Port        string   `short:"p" help:"Port to run the server on, or auto to use the next free one. Defaults to the server_port from config.yml, or 4001. Other projects use the next free port after their configured one."`
+	Env         string   `help:"Environment to serve, selects the config.<env>.yml overlay." env:"JORGE_ENV" default:"development"`
+	NoReload    bool     `help:"Disable live reloading."`
+	HTTPS       bool     `name:"https" help:"Serve over https, with a self-signed certificate for the server host and local network addresses."`
+	Write       bool     `help:"Also write the site files to the target directory, instead of just serving them from memory."`
+	Drafts      *bool    `negatable:"" help:"Include the draft posts. Defaults to the drafts setting from config.yml, or true. Can also be toggled from the /_jorge/ page."`
+	DirFlags    `embed:""`
 }
 
 func (cmd *Serve) Run(ctx *kong.Context) error {
-	config, err := config.LoadDev(cmd.ProjectDir, cmd.Host, cmd.Port, !cmd.NoReload)
+	if len(cmd.ProjectDirs) > 1 && cmd.DirFlags != (DirFlags{}) {
+		return fmt.Errorf("directory flags can't be used when serving several projects")
+	}
+
+	// each project gets its own server, watcher and live reload events
+	servers := make([]*siteServer, 0, len(cmd.ProjectDirs))
+	serverErr := make(chan error, len(cmd.ProjectDirs))
+	for i, projectDir := range cmd.ProjectDirs {
+		server := &siteServer{cmd: cmd, projectDir: projectDir, port: cmd.Port}
+		server.drafts.Store(cmd.Drafts)
+		if i > 0 {
+			// projects can't share a port, and they usually have the same default one
+			server.port = "auto"
+		}
+		if err := server.start(serverErr); err != nil {
+			for _, started := range servers {
+				started.close()
+			}
+			if len(cmd.ProjectDirs) > 1 {
+				return fmt.Errorf("%s: %w", projectDir, err)
+			}
+			return err
+		}
+		servers = append(servers, server)
+	}
+
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serverErr:
+		for _, server := range servers {
+			server.close()
+		}
+		return err
+	case <-signals.Done():
+	}
+
+	fmt.Println("\nshutting down")
+	stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server.shutdown(shutdownCtx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// A project served by the dev server.
+type siteServer struct {
+	cmd        *Serve
+	projectDir string
+	// the --port flag value for this project
+	port string
+	// the port found with --port auto, used instead of the configured one
+	autoPort int
+	// whether to include drafts, overriding the config. Nil to use the configured value.
+	drafts atomic.Pointer[bool]
+
+	server  *http.Server
+	watcher *fsnotify.Watcher
+	handler *siteHandler
+	// schedules a config reload and rebuild
+	reload func()
+}
+
+// Start serving the project and watching its files for changes. Errors found while
+// serving are sent to the given channel.
+func (server *siteServer) start(serverErr chan<- error) error {
+	config, err := server.loadConfig()
 	if err != nil {
 		return err
 	}
@@ -33,25 +147,289 @@ func (cmd *Serve) Run(ctx *kong.Context) error {
 		return fmt.Errorf("missing src directory")
 	}
 
-	// watch for changes in src and layouts, and trigger a rebuild
-	broker := newEventBroker()
-	watcher, err := runWatcher(config, broker)
+	// listen before building, so the address problems are reported right away
+	listener, err := server.listen(config)
 	if err != nil {
 		return err
 	}
-	defer watcher.Close()
+	if server.autoPort != 0 {
+		// load again to use the port found in the site url
+		if config, err = server.loadConfig(); err != nil {
+			listener.Close()
+			return err
+		}
+	}
 
-	// serve the target dir with a file server
-	fs := http.FileServer(http.Dir(config.TargetDir))
-	http.Handle("/", fs)
+	var tlsConfig *tls.Config
+	if server.cmd.HTTPS {
+		cert, err := localCertificate(config.ServerHost)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("can't setup the https certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	// watch for changes in src, layouts and config files, and trigger a rebuild
+	broker := newEventBroker()
+	server.handler = &siteHandler{writeTarget: server.cmd.Write}
+	server.watcher, server.reload, err = runWatcher(config, server.loadConfig, broker, server.handler)
+	if err != nil {
+		listener.Close()
+		return err
+	}
 
+	mux := http.NewServeMux()
+	// serve the site files built in memory
+	mux.Handle("/", server.handler)
 	if config.LiveReload {
 		// handle client requests to listen to server-sent events
-		http.Handle("/_events/", makeServerEventsHandler(broker))
+		mux.Handle("/_events/", makeServerEventsHandler(broker))
+	}
+	mux.HandleFunc("/_jorge/", server.serveDevIndex)
+	mux.HandleFunc("/_jorge/drafts", server.toggleDrafts)
+	server.server = &http.Server{Handler: mux, TLSConfig: tlsConfig}
+	// the event streams are only closed by clients, end them so shutdown doesn't wait for them
+	server.server.RegisterOnShutdown(broker.close)
+
+	if tlsConfig != nil {
+		go func() { serverErr <- server.server.ServeTLS(listener, "", "") }()
+	} else {
+		go func() { serverErr <- server.server.Serve(listener) }()
+	}
+	return nil
+}
+
+// Stop watching the project files and wait for the pending requests to finish.
+func (server *siteServer) shutdown(ctx context.Context) {
+	server.watcher.Close()
+	if err := server.server.Shutdown(ctx); err != nil {
+		// some requests didn't finish in time
+		server.server.Close()
+	}
+}
+
+// Stop watching the project files and serving them right away.
+func (server *siteServer) close() {
+	server.watcher.Close()
+	server.server.Close()
+}
+
+// Load the project config with the command flags applied.
+func (server *siteServer) loadConfig() (*config.Config, error) {
+	cmd := server.cmd
+	port := server.autoPort
+	if server.port != "" && server.port != "auto" {
+		var err error
+		if port, err = strconv.Atoi(server.port); err != nil {
+			return nil, fmt.Errorf("invalid port %s, expected a number or auto", strconv.Quote(server.port))
+		}
+	}
+	config, err := config.LoadDev(server.projectDir, cmd.Env, cmd.Host, port, cmd.HTTPS, !cmd.NoReload)
+	if err != nil {
+		return nil, err
+	}
+	cmd.DirFlags.apply(config)
+	if drafts := server.drafts.Load(); drafts != nil {
+		config.IncludeDrafts = *drafts
+	}
+	return config, nil
+}
+
+// Open the server listener at the configured address. With --port auto, the following ports
+// are tried when the configured one is taken, and the one found is kept for config reloads.
+func (server *siteServer) listen(config *config.Config) (net.Listener, error) {
+	for port := config.ServerPort; ; port++ {
+		listener, err := net.Listen("tcp", net.JoinHostPort(config.ServerHost, strconv.Itoa(port)))
+		if err == nil {
+			if port != config.ServerPort {
+				fmt.Printf("port %d is in use, using %d instead\n", config.ServerPort, port)
+				server.autoPort = port
+			}
+			return listener, nil
+		}
+		if !isAddrInUse(err) {
+			return nil, err
+		}
+		if server.port != "auto" {
+			return nil, fmt.Errorf("port %d is already in use, pass --port auto to use the next free one", port)
+		}
+		if port == 65535 || port-config.ServerPort >= MAX_AUTO_PORTS {
+			return nil, fmt.Errorf("no free port found between %d and %d", config.ServerPort, port)
+		}
+	}
+}
+
+// Serves the files of the latest site build from memory. The files are replaced as a whole
+// after each rebuild, so requests never see a partially built site.
+type siteHandler struct {
+	files atomic.Pointer[site.MemoryFS]
+	// the config with the routing rules, replaced when it's reloaded
+	config atomic.Pointer[config.Config]
+	// whether rebuilds should also write the files to the target directory
+	writeTarget bool
+	// the pages listed by the dev index, loaded on demand once per build
+	pages atomic.Pointer[pageList]
+}
+
+type pageList struct {
+	once   sync.Once
+	config *config.Config
+	pages  []site.PageInfo
+	err    error
+}
+
+// Build the site and serve the resulting files, unless the build failed.
+func (handler *siteHandler) rebuild(config *config.Config) (*site.BuildReport, error) {
+	handler.config.Store(config)
+	report, files, err := site.BuildInMemory(*config, handler.writeTarget)
+	if files != nil {
+		handler.files.Store(files)
 	}
+	handler.pages.Store(&pageList{config: config})
+	return report, err
+}
 
-	addr := fmt.Sprintf("%s:%d", config.ServerHost, config.ServerPort)
-	return http.ListenAndServe(addr, nil)
+// Return the site pages as of the latest build. They are only loaded the first time they're requested.
+func (handler *siteHandler) listPages() ([]site.PageInfo, error) {
+	list := handler.pages.Load()
+	if list == nil {
+		return nil, fmt.Errorf("the site is not built yet")
+	}
+	list.once.Do(func() {
+		list.pages, list.err = site.ListPages(*list.config)
+	})
+	return list.pages, list.err
+}
+
+// Serve the site files, applying the site redirects and the rewrite and header rules from the config,
+// and falling back to the site 404.html page for missing files.
+func (handler *siteHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	files, config := handler.files.Load(), handler.config.Load()
+	if files == nil {
+		http.Error(res, "the site is not built yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	// not a permanent redirect, so browsers don't cache it while the site is being edited
+	if to, found := files.Redirect(req.URL.Path); found {
+		http.Redirect(res, req, to, http.StatusFound)
+		return
+	}
+
+	for name, values := range config.ResponseHeaders(req.URL.Path) {
+		res.Header()[name] = values
+	}
+
+	name, isDirIndex, found := resolveFile(files, req.URL.Path)
+	isRewrite := false
+	if !found {
+		// as with try_files, rewrites only apply to paths that don't match a file
+		if rewritten, matched := config.RewritePath(req.URL.Path); matched {
+			name, _, found = resolveFile(files, rewritten)
+			isRewrite = true
+		}
+	}
+	if found {
+		// directories are redirected to their trailing slash url, as the file server would do
+		if isDirIndex && !isRewrite && !strings.HasSuffix(req.URL.Path, "/") {
+			target := path.Base(req.URL.Path) + "/"
+			if req.URL.RawQuery != "" {
+				target += "?" + req.URL.RawQuery
+			}
+			http.Redirect(res, req, target, http.StatusMovedPermanently)
+			return
+		}
+		serveFile(res, req, files, name)
+		return
+	}
+
+	// a 404.html template is rendered to 404/index.html
+	for _, name := range []string{"404.html", "404/index.html"} {
+		if content, err := fs.ReadFile(files, name); err == nil {
+			res.Header().Set("Content-Type", "text/html; charset=utf-8")
+			res.WriteHeader(http.StatusNotFound)
+			res.Write(content)
+			return
+		}
+	}
+	http.NotFound(res, req)
+}
+
+// Return the name of the file to serve for the given url path, trying the same alternatives
+// as the production nginx config (docs/nginx-sites-available): the path as a file, with an .html
+// extension and as a directory with an index.html, also without the trailing slash.
+// Reports whether the file is the index of the requested directory.
+func resolveFile(files fs.FS, urlPath string) (string, bool, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		name = "."
+	}
+
+	dirIndex := path.Join(name, "index.html")
+	candidates := []string{name, name + ".html", dirIndex}
+	if strings.HasSuffix(urlPath, "/") {
+		candidates = []string{dirIndex, name, name + ".html"}
+	}
+	for _, candidate := range candidates {
+		info, err := fs.Stat(files, candidate)
+		if err == nil && !info.IsDir() {
+			return candidate, candidate == dirIndex, true
+		}
+	}
+	return "", false, false
+}
+
+// Serve the named file, or its precompressed version if there's one in an encoding accepted by the client.
+func serveFile(res http.ResponseWriter, req *http.Request, files fs.FS, name string) {
+	varies := false
+	for _, encoding := range markup.ENCODINGS {
+		if _, err := fs.Stat(files, name+encoding.Ext); err != nil {
+			continue
+		}
+		if !varies {
+			res.Header().Add("Vary", "Accept-Encoding")
+			varies = true
+		}
+		if acceptsEncoding(req.Header.Get("Accept-Encoding"), encoding.Name) {
+			// set the type of the original file, otherwise it would be detected from the encoding extension
+			contentType := mime.TypeByExtension(path.Ext(name))
+			if contentType == "" {
+				// only text files are compressed
+				contentType = "text/plain; charset=utf-8"
+			}
+			res.Header().Set("Content-Type", contentType)
+			res.Header().Set("Content-Encoding", encoding.Name)
+			http.ServeFileFS(res, req, files, name+encoding.Ext)
+			return
+		}
+	}
+	http.ServeFileFS(res, req, files, name)
+}
+
+// Report whether the given Accept-Encoding header value allows the encoding,
+// either explicitly or with a wildcard, and without a zero weight.
+// An explicit entry for the encoding takes precedence over the wildcard.
+func acceptsEncoding(header string, encoding string) bool {
+	wildcard := false
+	for _, option := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(option, ";")
+		name = strings.TrimSpace(name)
+		if name != encoding && name != "*" {
+			continue
+		}
+		accepted := true
+		if weight, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if value, err := strconv.ParseFloat(weight, 64); err == nil && value == 0 {
+				accepted = false
+			}
+		}
+		if name == encoding {
+			return accepted
+		}
+		wildcard = accepted
+	}
+	return wildcard
 }
 
 // Return an http.HandlerFunc that establishes a server-sent event stream with clients,
@@ -67,15 +445,28 @@ func makeServerEventsHandler(broker *EventBroker) http.HandlerFunc {
 		id, events := broker.subscribe()
 		for {
 			select {
-			case <-events:
-				// send an event to the connected client.
-				// data\n\n just means send an empty, unnamed event
-				// since we only need to support the single reload operation.
+			case event, ok := <-events:
+				if !ok {
+					// the broker was closed
+					return
+				}
+				// send a named event to the connected client, which the injected
+				// live reload script handles according to its type
+				data, err := json.Marshal(event.Data)
+				if err != nil {
+					fmt.Println("couldn't encode server event:", err)
+					continue
+				}
 				fmt.Fprint(res, "retry: 1000\n")
-				fmt.Fprint(res, "data\n\n")
+				fmt.Fprintf(res, "event: %s\n", event.Type)
+				fmt.Fprintf(res, "data: %s\n\n", data)
 				res.(http.Flusher).Flush()
 			case <-req.Context().Done():
-				broker.unsubscribe(id)
+				// the broker may be blocked sending an event to this client,
+				// so keep draining them until the subscription is closed
+				go broker.unsubscribe(id)
+				for range events {
+				}
 				return
 			}
 		}
@@ -83,18 +474,47 @@ func makeServerEventsHandler(broker *EventBroker) http.HandlerFunc {
 }
 
 // Sets up a watcher that will publish changes in the site source files
-// to the returned event broker.
-func runWatcher(config *config.Config, broker *EventBroker) (*fsnotify.Watcher, error) {
+// to the returned event broker. When the config files change, the config is reloaded
+// with the given function before rebuilding. The returned function forces such a reload,
+// for changes to the config that don't come from its files.
+func runWatcher(initialConfig *config.Config, loadConfig func() (*config.Config, error), broker *EventBroker, handler *siteHandler) (*fsnotify.Watcher, func(), error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	// the config is replaced on reloads, while the watcher goroutine keeps reading it
+	var current atomic.Pointer[config.Config]
+	current.Store(initialConfig)
 
 	// the rebuild is handled after some delay to prevent bursts of events to trigger repeated rebuilds
 	// which can cause the browser to refresh while another unfinished build is in progress (refreshing to
 	// a missing file). The initial build is done immediately.
+	changes := &changedFiles{}
+	var lastBuildFailed atomic.Bool
+	var forceReload atomic.Bool
 	rebuildAfter := time.AfterFunc(0, func() {
-		rebuildSite(config, watcher, broker)
+		changedPaths := changes.flush()
+		config := current.Load()
+		if forceReload.Swap(false) || slices.ContainsFunc(changedPaths, func(path string) bool { return isConfigFile(config, path) }) {
+			newConfig, ok := reloadConfig(config, loadConfig, broker)
+			if !ok {
+				lastBuildFailed.Store(true)
+				return
+			}
+			// the project directories may have changed, they are re-added when rebuilding
+			for _, path := range watcher.WatchList() {
+				watcher.Remove(path)
+			}
+			current.Store(newConfig)
+			config = newConfig
+		}
+
+		event := reloadEvent(config, changedPaths)
+		if lastBuildFailed.Load() {
+			// pages may have been left out of the previous build
+			event = FULL_RELOAD_EVENT
+		}
+		lastBuildFailed.Store(!rebuildSite(config, watcher, broker, handler, event))
 	})
 
 	go func() {
@@ -102,25 +522,78 @@ func runWatcher(config *config.Config, broker *EventBroker) (*fsnotify.Watcher,
 			// chmod events are noisy, ignore them. But not if they are also a write event.
 			isChmod := event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write)
 			// Also ignore dot file events, which are usually spurious (e.g .DS_Store, emacs temp files)
-			isDotFile := strings.HasPrefix(filepath.Base(event.Name), ".")
-			if isChmod || isDotFile {
+			// and the ones for src files excluded from the site
+			if isChmod || isIgnoredFile(current.Load(), event.Name) {
 				continue
 			}
 
 			// Schedule a rebuild to trigger after a delay. If there was another one pending
 			// it will be canceled.
 			fmt.Printf("\nfile %s changed\n", event.Name)
+			changes.add(event.Name)
 			rebuildAfter.Stop()
 			rebuildAfter.Reset(100 * time.Millisecond)
 		}
 	}()
 
-	return watcher, err
+	reload := func() {
+		forceReload.Store(true)
+		rebuildAfter.Stop()
+		rebuildAfter.Reset(0)
+	}
+	return watcher, reload, err
+}
+
+// The paths of the files changed since the last rebuild.
+type changedFiles struct {
+	mutex sync.Mutex
+	paths []string
+}
+
+func (changes *changedFiles) add(path string) {
+	changes.mutex.Lock()
+	defer changes.mutex.Unlock()
+	changes.paths = append(changes.paths, path)
+}
+
+// Return the changed paths, clearing them for the next rebuild.
+func (changes *changedFiles) flush() []string {
+	changes.mutex.Lock()
+	defer changes.mutex.Unlock()
+	paths := changes.paths
+	changes.paths = nil
+	return paths
+}
+
+// Return the kind of reload clients need to reflect the changes to the given files.
+func reloadEvent(config *config.Config, changedPaths []string) string {
+	if len(changedPaths) == 0 {
+		// the initial build
+		return FULL_RELOAD_EVENT
+	}
+
+	event := CSS_RELOAD_EVENT
+	for _, path := range changedPaths {
+		if relPath, err := filepath.Rel(config.SrcDir, path); err != nil || strings.HasPrefix(relPath, "..") {
+			// layouts, includes or data files, which may be used by any page
+			return FULL_RELOAD_EVENT
+		}
+		if _, err := os.Stat(path); err != nil {
+			// removed or renamed files may leave links to them in any page
+			return FULL_RELOAD_EVENT
+		}
+		if filepath.Ext(path) != ".css" {
+			event = PAGE_RELOAD_EVENT
+		}
+	}
+	return event
 }
 
 // React to source file change events by re-watching the source directories,
-// rebuilding the site and publishing a rebuild event to clients.
-func rebuildSite(config *config.Config, watcher *fsnotify.Watcher, broker *EventBroker) {
+// rebuilding the site for the handler to serve it and publishing the given reload event to clients,
+// or a build error event with the problems found if some file failed to render.
+// Return false if the build failed.
+func rebuildSite(config *config.Config, watcher *fsnotify.Watcher, broker *EventBroker, handler *siteHandler, reloadEvent string) bool {
 	fmt.Printf("building site\n")
 	start := time.Now()
 
@@ -130,28 +603,97 @@ func rebuildSite(config *config.Config, watcher *fsnotify.Watcher, broker *Event
 		fmt.Println("couldn't add watchers:", err)
 	}
 
-	if err := site.Build(*config); err != nil {
+	report, err := handler.rebuild(config)
+	if err != nil {
 		fmt.Println("build error:", err)
-		return
+		issues := []site.BuildIssue{{Message: err.Error()}}
+		if report != nil && len(report.Errors) > 0 {
+			issues = report.Errors
+		}
+		broker.publish(ServerEvent{Type: BUILD_ERROR_EVENT, Data: issues})
+		return false
+	}
+	if len(report.Errors) > 0 {
+		// the dev server keeps going on render errors, but clients should show them
+		broker.publish(ServerEvent{Type: BUILD_ERROR_EVENT, Data: report.Errors})
+	} else {
+		broker.publish(ServerEvent{Type: reloadEvent})
 	}
-
-	broker.publish("rebuild")
 
 	elapsed := time.Since(start)
 	fmt.Printf("done in %.2fs\nserving at %s\n", elapsed.Seconds(), config.SiteUrl)
+	return len(report.Errors) == 0
+}
+
+// Load the config again after changes to its files. If the new config is invalid, report
+// the error to clients and return false. Changes that require restarting the server,
+// like its address, are reported and left out.
+func reloadConfig(previous *config.Config, loadConfig func() (*config.Config, error), broker *EventBroker) (*config.Config, bool) {
+	fmt.Println("reloading config")
+	newConfig, err := loadConfig()
+	if err != nil {
+		fmt.Println("config error:", err)
+		issue := site.BuildIssue{Message: err.Error()}
+		var configErr *config.ConfigError
+		if errors.As(err, &configErr) && configErr.Line != 0 {
+			source, _ := filepath.Rel(previous.RootDir, configErr.Path)
+			issue = site.BuildIssue{Source: source, Line: configErr.Line, Message: configErr.Message}
+		}
+		broker.publish(ServerEvent{Type: BUILD_ERROR_EVENT, Data: []site.BuildIssue{issue}})
+		return nil, false
+	}
+
+	if newConfig.ServerHost != previous.ServerHost || newConfig.ServerPort != previous.ServerPort {
+		fmt.Printf("server address changed to %s:%d, restart the server to apply it\n", newConfig.ServerHost, newConfig.ServerPort)
+		newConfig.ServerHost = previous.ServerHost
+		newConfig.ServerPort = previous.ServerPort
+		newConfig.SiteUrl = previous.SiteUrl
+	}
+	return newConfig, true
+}
+
+// Report whether the given path is one of the project config files.
+func isConfigFile(config *config.Config, path string) bool {
+	return slices.Contains(config.ConfigFiles(), filepath.Clean(path))
+}
+
+// Report whether changes to the file at the given path should not trigger a rebuild.
+func isIgnoredFile(config *config.Config, path string) bool {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return true
+	}
+	// the project root is watched for config changes only
+	rootDir := filepath.Clean(config.RootDir)
+	if filepath.Dir(path) == rootDir && filepath.Clean(config.SrcDir) != rootDir {
+		return !isConfigFile(config, path)
+	}
+	relPath, err := filepath.Rel(config.SrcDir, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		// not a src file
+		return false
+	}
+	info, err := os.Stat(path)
+	isDir := err == nil && info.IsDir()
+	return config.IsExcluded(relPath, isDir)
 }
 
 // Configure the given watcher to notify for changes in the project source files
 func watchProjectFiles(watcher *fsnotify.Watcher, config *config.Config) error {
+	// watch the root dir instead of the config files, since editors may replace them on save
+	watcher.Add(config.RootDir)
 	watcher.Add(config.LayoutsDir)
 	watcher.Add(config.DataDir)
 	watcher.Add(config.IncludesDir)
 	// fsnotify watches all files within a dir, but non recursively
 	// this walks through the src dir and adds watches for each found directory
 	return filepath.WalkDir(config.SrcDir, func(path string, entry fs.DirEntry, err error) error {
-		if entry.IsDir() {
-			watcher.Add(path)
+		if err != nil || !entry.IsDir() {
+			return nil
 		}
+		if relPath, _ := filepath.Rel(config.SrcDir, path); config.IsExcluded(relPath, true) {
+			return filepath.SkipDir
+		}
+		watcher.Add(path)
 		return nil
 	})
 }
@@ -160,22 +702,26 @@ func watchProjectFiles(watcher *fsnotify.Watcher, config *config.Config) error {
 // that publishes site rebuild events
 // and the clients listening for them to refresh the browser
 type EventBroker struct {
-	inEvents        chan string
+	inEvents        chan ServerEvent
 	inSubscriptions chan Subscription
-	subscribers     map[uint64]chan string
+	subscribers     map[uint64]chan ServerEvent
 	idgen           atomic.Uint64
+	// closed to stop the broker and end its subscriptions
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 type Subscription struct {
 	id        uint64
-	outEvents chan string
+	outEvents chan ServerEvent
 }
 
 func newEventBroker() *EventBroker {
 	broker := EventBroker{
-		inEvents:        make(chan string),
+		inEvents:        make(chan ServerEvent),
 		inSubscriptions: make(chan Subscription),
-		subscribers:     map[uint64]chan string{},
+		subscribers:     map[uint64]chan ServerEvent{},
+		done:            make(chan struct{}),
 	}
 
 	go func() {
@@ -185,16 +731,24 @@ func newEventBroker() *EventBroker {
 				if msg.outEvents != nil {
 					// subscribe
 					broker.subscribers[msg.id] = msg.outEvents
-				} else {
+				} else if outEvents, found := broker.subscribers[msg.id]; found {
 					// unsubscribe
-					close(broker.subscribers[msg.id])
+					close(outEvents)
 					delete(broker.subscribers, msg.id)
 				}
 			case msg := <-broker.inEvents:
 				// send the event to all the subscribers
 				for _, outEvents := range broker.subscribers {
-					outEvents <- msg
+					select {
+					case outEvents <- msg:
+					case <-broker.done:
+					}
+				}
+			case <-broker.done:
+				for _, outEvents := range broker.subscribers {
+					close(outEvents)
 				}
+				return
 			}
 		}
 	}()
@@ -203,20 +757,36 @@ func newEventBroker() *EventBroker {
 
 // Adds a subscription to this broker events, returning a subscriber id
 // (useful for unsubscribing later) and a channel where events will be delivered.
-func (broker *EventBroker) subscribe() (uint64, <-chan string) {
+// The channel is closed right away if the broker is closed.
+func (broker *EventBroker) subscribe() (uint64, <-chan ServerEvent) {
 	id := broker.idgen.Add(1)
-	outEvents := make(chan string)
-	broker.inSubscriptions <- Subscription{id, outEvents}
+	outEvents := make(chan ServerEvent)
+	select {
+	case broker.inSubscriptions <- Subscription{id, outEvents}:
+	case <-broker.done:
+		close(outEvents)
+	}
 	return id, outEvents
 }
 
 // Remove the subscriber with the given id from the broker,
 // closing its associated channel.
 func (broker *EventBroker) unsubscribe(id uint64) {
-	broker.inSubscriptions <- Subscription{id: id, outEvents: nil}
+	select {
+	case broker.inSubscriptions <- Subscription{id: id, outEvents: nil}:
+	case <-broker.done:
+	}
+}
+
+// Publish an event to all the broker subscribers. Events published after closing the broker are dropped.
+func (broker *EventBroker) publish(event ServerEvent) {
+	select {
+	case broker.inEvents <- event:
+	case <-broker.done:
+	}
 }
 
-// Publish an event to all the broker subscribers.
-func (broker *EventBroker) publish(event string) {
-	broker.inEvents <- event
+// Stop the broker, closing the channels of all its subscribers.
+func (broker *EventBroker) close() {
+	broker.closeOnce.Do(func() { close(broker.done) })
 }
@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+func TestResolveFile(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":           {Data: []byte("home")},
+		"blog/post/index.html": {Data: []byte("post")},
+		"docs.html":            {Data: []byte("docs")},
+		"feed.xml":             {Data: []byte("feed")},
+		"assets/main.css":      {Data: []byte("body {}")},
+	}
+
+	tests := []struct {
+		urlPath    string
+		name       string
+		isDirIndex bool
+		found      bool
+	}{
+		{"/", "index.html", true, true},
+		{"/index.html", "index.html", false, true},
+		{"/blog/post", "blog/post/index.html", true, true},
+		{"/blog/post/", "blog/post/index.html", true, true},
+		{"/docs", "docs.html", false, true},
+		{"/docs/", "docs.html", false, true},
+		{"/feed.xml", "feed.xml", false, true},
+		{"/feed.xml/", "feed.xml", false, true},
+		{"/blog/../docs", "docs.html", false, true},
+		{"/../../feed.xml", "feed.xml", false, true},
+		// directories without an index are not listed
+		{"/assets/", "", false, false},
+		{"/blog", "", false, false},
+		{"/missing", "", false, false},
+	}
+	for _, test := range tests {
+		name, isDirIndex, found := resolveFile(files, test.urlPath)
+		if name != test.name || isDirIndex != test.isDirIndex || found != test.found {
+			t.Errorf("resolveFile(%s) = %s, %v, %v, expected %s, %v, %v", test.urlPath,
+				name, isDirIndex, found, test.name, test.isDirIndex, test.found)
+		}
+	}
+}
+
+func TestSiteHandler(t *testing.T) {
+	project := newProject()
+	defer os.RemoveAll(project.RootDir)
+	project.Redirects = map[string]string{"/old": "/about"}
+	project.Rewrites = []config.Rewrite{{From: "app/**", To: "/app/index.html"}}
+	project.Headers = []config.ResponseHeaders{{Path: "about", Values: map[string]string{"X-Test": "about"}}}
+	newFile(project.SrcDir, "404.html", "---\n---\n<p>not found</p>")
+	newFile(project.SrcDir, "about.html", "---\nredirect_from: /me\n---\n<p>about</p>")
+	newFile(project.SrcDir, "app/index.html", "<p>app</p>")
+	newFile(project.SrcDir, "app/main.js", "console.log('app')")
+
+	handler := &siteHandler{}
+	response := serveRequest(handler, "/about/")
+	assertEqual(t, response.Code, http.StatusServiceUnavailable)
+
+	_, err := handler.rebuild(project)
+	assertEqual(t, err, nil)
+
+	tests := []struct {
+		urlPath string
+		status  int
+		body    string
+		headers map[string]string
+	}{
+		{"/about/", http.StatusOK, "<p>about</p>", map[string]string{"X-Test": "about"}},
+		{"/about", http.StatusMovedPermanently, "", map[string]string{"Location": "/about/", "X-Test": "about"}},
+		{"/old", http.StatusFound, "", map[string]string{"Location": "/about"}},
+		{"/me/", http.StatusFound, "", map[string]string{"Location": "/about"}},
+		{"/missing", http.StatusNotFound, "<p>not found</p>", map[string]string{"Content-Type": "text/html; charset=utf-8"}},
+		// rewrites only apply to missing files
+		{"/app/settings/profile", http.StatusOK, "<p>app</p>", nil},
+		{"/app/main.js", http.StatusOK, "console.log('app')", map[string]string{"Content-Type": mime.TypeByExtension(".js")}},
+	}
+	for _, test := range tests {
+		response := serveRequest(handler, test.urlPath)
+		if response.Code != test.status {
+			t.Errorf("GET %s: expected status %d, got %d", test.urlPath, test.status, response.Code)
+		}
+		if !strings.Contains(response.Body.String(), test.body) {
+			t.Errorf("GET %s: expected body to contain %s, got %s", test.urlPath, test.body, response.Body)
+		}
+		for name, value := range test.headers {
+			if response.Header().Get(name) != value {
+				t.Errorf("GET %s: expected header %s: %s, got %s", test.urlPath, name, value, response.Header().Get(name))
+			}
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		encoding string
+		expected bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"gzip, deflate, br", "br", true},
+		{"gzip,deflate", "br", false},
+		{"  br ;q=0.5 ,gzip", "br", true},
+		{"br;q=0", "br", false},
+		{"br;q=0.0, gzip", "br", false},
+		{"br;q=0.001", "br", true},
+		{"*", "br", true},
+		{"*;q=0", "br", false},
+		{"*;q=0, br", "br", true},
+		// explicit entries take precedence over the wildcard
+		{"*, br;q=0", "br", false},
+		{"identity", "gzip", false},
+		{"gzipped", "gzip", false},
+	}
+	for _, test := range tests {
+		if acceptsEncoding(test.header, test.encoding) != test.expected {
+			t.Errorf("acceptsEncoding(%q, %s) expected %v", test.header, test.encoding, test.expected)
+		}
+	}
+}
+
+func TestServeFileEncodings(t *testing.T) {
+	files := fstest.MapFS{
+		"main.js":          {Data: []byte("js")},
+		"main.js.br":       {Data: []byte("js br")},
+		"main.js.gz":       {Data: []byte("js gzip")},
+		"feed.xml":         {Data: []byte("xml")},
+		"feed.xml.gz":      {Data: []byte("xml gzip")},
+		"notes":            {Data: []byte("notes")},
+		"notes.gz":         {Data: []byte("notes gzip")},
+		"images/photo.jpg": {Data: []byte("jpg")},
+	}
+	jsType := mime.TypeByExtension(".js")
+	xmlType := mime.TypeByExtension(".xml")
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		contentType    string
+		encoding       string
+		vary           bool
+	}{
+		{"main.js", "", "js", jsType, "", true},
+		{"main.js", "gzip, br", "js br", jsType, "br", true},
+		{"main.js", "gzip", "js gzip", jsType, "gzip", true},
+		{"main.js", "br;q=0, gzip", "js gzip", jsType, "gzip", true},
+		{"feed.xml", "br", "xml", xmlType, "", true},
+		{"feed.xml", "br, gzip", "xml gzip", xmlType, "gzip", true},
+		// files without a known type are assumed to be text, since only those are compressed
+		{"notes", "gzip", "notes gzip", "text/plain; charset=utf-8", "gzip", true},
+		// files without a compressed version don't vary by encoding
+		{"images/photo.jpg", "gzip, br", "jpg", mime.TypeByExtension(".jpg"), "", false},
+	}
+	for _, test := range tests {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/"+test.name, nil)
+		if test.acceptEncoding != "" {
+			request.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		serveFile(response, request, files, test.name)
+
+		header := response.Header()
+		if response.Body.String() != test.body || header.Get("Content-Type") != test.contentType ||
+			header.Get("Content-Encoding") != test.encoding || (header.Get("Vary") == "Accept-Encoding") != test.vary {
+			t.Errorf("serveFile(%s) with Accept-Encoding %q: got body %q, Content-Type %q, Content-Encoding %q, Vary %q",
+				test.name, test.acceptEncoding, response.Body, header.Get("Content-Type"),
+				header.Get("Content-Encoding"), header.Get("Vary"))
+		}
+	}
+}
+
+func TestListenAutoPort(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	assertEqual(t, err, nil)
+	defer taken.Close()
+	port := taken.Addr().(*net.TCPAddr).Port
+	project := &config.Config{ServerHost: "127.0.0.1", ServerPort: port}
+
+	server := &siteServer{port: strconv.Itoa(port)}
+	_, err = server.listen(project)
+	assert(t, err != nil)
+	assert(t, strings.Contains(err.Error(), "already in use"))
+
+	server = &siteServer{port: "auto"}
+	listener, err := server.listen(project)
+	assertEqual(t, err, nil)
+	defer listener.Close()
+	assert(t, server.autoPort > port)
+	assertEqual(t, listener.Addr().(*net.TCPAddr).Port, server.autoPort)
+}
+
+func TestEventBrokerClose(t *testing.T) {
+	broker := newEventBroker()
+	_, events := broker.subscribe()
+	_, otherEvents := broker.subscribe()
+
+	go broker.publish(ServerEvent{Type: "rebuild"})
+	// subscribers are sent the event in any order
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events:
+			assertEqual(t, event.Type, "rebuild")
+		case event := <-otherEvents:
+			assertEqual(t, event.Type, "rebuild")
+		}
+	}
+
+	broker.close()
+	_, open := <-events
+	assert(t, !open)
+	_, open = <-otherEvents
+	assert(t, !open)
+
+	// using the broker after closing it doesn't block
+	broker.publish(ServerEvent{Type: "rebuild"})
+	_, lateEvents := broker.subscribe()
+	_, open = <-lateEvents
+	assert(t, !open)
+	broker.close()
+}
+
+// ------ HELPERS --------
+
+func serveRequest(handler http.Handler, urlPath string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, urlPath, nil))
+	return response
+}
+
+func newProject() *config.Config {
+	projectDir, _ := os.MkdirTemp("", "root")
+	os.Mkdir(filepath.Join(projectDir, "src"), DIR_RWE_MODE)
+	project, _ := config.Load(projectDir, "")
+	project.Minify = false
+	return project
+}
+
+func newFile(dir string, filename string, contents string) {
+	path := filepath.Join(dir, filepath.FromSlash(filename))
+	os.MkdirAll(filepath.Dir(path), DIR_RWE_MODE)
+	os.WriteFile(path, []byte(contents), FILE_RW_MODE)
+}
+
+func assert(t *testing.T, cond bool) {
+	t.Helper()
+	if !cond {
+		t.Fatalf("%v is false", cond)
+	}
+}
+
+func assertEqual(t *testing.T, a interface{}, b interface{}) {
+	t.Helper()
+	if a != b {
+		t.Fatalf("%v != %v", a, b)
+	}
+}
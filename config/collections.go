@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A named group of templates, declared under the `collections` key of config.yml, e.g.:
+//
+//	collections:
+//	  projects:
+//	    dir: projects
+//	    sort_by: title
+//	    layout: project
+//	    permalink: /work/:name/
+//	  talks:
+//	    glob: "talks/**/*.md"
+//	    sort_by: date
+//	    reverse: true
+//	    output: false
+type Collection struct {
+	Name string `yaml:"-"`
+
+	// the templates of the collection are the ones found within Dir or matching Glob,
+	// both relative to the src directory.
+	Dir  string `yaml:"dir"`
+	Glob string `yaml:"glob"`
+
+	// the front matter key used to sort the collection items, `path` by default.
+	SortBy  string `yaml:"sort_by"`
+	Reverse bool   `yaml:"reverse"`
+
+	// the layout used for items that don't set one in their front matter.
+	Layout string `yaml:"layout"`
+
+	// the url pattern for the items of the collection, see `TargetPath`.
+	Permalink string `yaml:"permalink"`
+
+	// when false, the collection items are available to templates but not written to the target.
+	Output bool `yaml:"output"`
+}
+
+// Return a collection with the default settings.
+func newCollection(name string) Collection {
+	return Collection{Name: name, SortBy: "path", Output: true}
+}
+
+// Report whether the template at the given path, relative to the src directory,
+// belongs to this collection.
+func (collection Collection) Contains(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	if collection.Dir != "" {
+		dir := strings.Trim(filepath.ToSlash(collection.Dir), "/")
+		if strings.HasPrefix(relPath, dir+"/") {
+			return true
+		}
+	}
+	return collection.Glob != "" && matchGlob(collection.Glob, relPath)
+}
+
+// Resolve the collection permalink pattern for the given template, returning its path
+// relative to the target directory. The pattern supports the following placeholders:
+// `:collection`, the collection name; `:path`, the template path relative to the collection
+// directory, without extension; `:name`, the template file name without extension;
+// `:year`, `:month` and `:day`, from the template date. Using these with an undated template
+// is an error.
+// Patterns resolving to html files produce pretty uris (an index.html file in a directory
+// named after the pattern). An empty pattern returns an empty string.
+func (collection Collection) TargetPath(relPath string, targetExt string, date time.Time) (string, error) {
+	if collection.Permalink == "" {
+		return "", nil
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	withoutExt := strings.TrimSuffix(relPath, filepath.Ext(relPath))
+	collectionPath := withoutExt
+	if collection.Dir != "" {
+		collectionPath = strings.TrimPrefix(withoutExt, strings.Trim(filepath.ToSlash(collection.Dir), "/")+"/")
+	}
+
+	replacements := []string{
+		":collection", collection.Name,
+		":path", collectionPath,
+		":name", filepath.Base(withoutExt),
+	}
+	if !date.IsZero() {
+		replacements = append(replacements,
+			":year", fmt.Sprintf("%d", date.Year()),
+			":month", fmt.Sprintf("%02d", date.Month()),
+			":day", fmt.Sprintf("%02d", date.Day()))
+	} else if strings.Contains(collection.Permalink, ":year") ||
+		strings.Contains(collection.Permalink, ":month") ||
+		strings.Contains(collection.Permalink, ":day") {
+		return "", fmt.Errorf("the %s permalink %s requires a date", collection.Name, collection.Permalink)
+	}
+	permalink := strings.NewReplacer(replacements...).Replace(collection.Permalink)
+	permalink = strings.TrimPrefix(permalink, "/")
+
+	if strings.HasSuffix(permalink, "/") || permalink == "" {
+		return filepath.FromSlash(permalink + "index.html"), nil
+	}
+	if filepath.Ext(permalink) == "" {
+		permalink += targetExt
+	}
+	// ensure pretty uris, same as the rest of the site html files
+	if filepath.Ext(permalink) == ".html" && path.Base(permalink) != "index.html" {
+		permalink = strings.TrimSuffix(permalink, ".html") + "/index.html"
+	}
+	return filepath.FromSlash(permalink), nil
+}
+
+// The collections as declared in config.yml: a mapping of names to collection settings.
+type collectionList []Collection
+
+// Decode the collections mapping, sorted by name. The settings of collections already
+// in the list are merged with the decoded ones, so config overlays can change individual keys.
+func (collections *collectionList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return &lineError{node.Line, "", fmt.Sprintf("expected a mapping of names to settings, got %s", describeNode(node))}
+	}
+
+	decoded := slices.Clone(*collections)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, settings := node.Content[i].Value, node.Content[i+1]
+		if settings.Kind != yaml.MappingNode {
+			return &lineError{settings.Line, name, fmt.Sprintf("expected a mapping of settings, got %s", describeNode(settings))}
+		}
+		if err := checkSettingTypes(name, settings, "reverse", "output"); err != nil {
+			return err
+		}
+
+		index := slices.IndexFunc(decoded, func(collection Collection) bool {
+			return collection.Name == name
+		})
+		if index == -1 {
+			decoded = append(decoded, newCollection(name))
+			index = len(decoded) - 1
+		}
+		collection := &decoded[index]
+		if err := settings.Decode(collection); err != nil {
+			return err
+		}
+		if collection.Dir == "" && collection.Glob == "" {
+			return &lineError{settings.Line, name, "needs either a dir or a glob"}
+		}
+		if collection.Glob != "" {
+			if err := validateGlob(collection.Glob); err != nil {
+				return &lineError{settings.Line, name, err.Error()}
+			}
+		}
+	}
+	slices.SortFunc(decoded, func(a Collection, b Collection) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	*collections = decoded
+	return nil
+}
+
+// Encode the collections back to a mapping of names to settings.
+func (collections collectionList) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, collection := range collections {
+		var settings yaml.Node
+		if err := settings.Encode(collection); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: collection.Name}, &settings)
+	}
+	return node, nil
+}
@@ -4,10 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
-
-	"gopkg.in/yaml.v3"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 // The properties that are depended upon in the source code are declared explicitly in the config struct.
@@ -26,6 +29,9 @@ type Config struct {
 	IncludesDir string
 	DataDir     string
 
+	// the name of the environment the site is built for, which selects the config.<env>.yml overlay
+	Env string
+
 	SiteUrl        string
 	PostFormat     string
 	Lang           string
@@ -33,104 +39,245 @@ type Config struct {
 
 	Minify           bool
 	MinifyExclusions []string
-	LiveReload       bool
-	LinkStatic       bool
-	IncludeDrafts    bool
+	// whether to write gzip and brotli compressed versions of the text files, for servers
+	// that support precompressed files, and the minimum size of the files to compress
+	Compress        bool
+	CompressMinSize int
+	LiveReload      bool
+	LinkStatic      bool
+	IncludeDrafts   bool
+
+	// whether to finish the build successfully even if some files fail to render,
+	// and whether to fail it if there are warnings (e.g. missing layouts or includes)
+	KeepGoing     bool
+	FailOnWarning bool
 
 	ServerHost string
 	ServerPort int
 
-	pageDefaults map[string]interface{}
+	// gitignore-style patterns of src files to leave out of the site, and of excluded files
+	// (e.g. dot files) to keep in it
+	Exclude []string
+	Include []string
+
+	Collections []Collection
+
+	Defaults []FrontMatterDefault
+
+	Deploy []DeployTarget
+
+	// paths that redirect to other urls, and the development server routing rules
+	Redirects map[string]string
+	Rewrites  []Rewrite
+	Headers   []ResponseHeaders
 
 	// the user provided overrides, as found in config.yml
 	// these will passed as found as template context
 	overrides map[string]interface{}
 }
 
-func Load(rootDir string) (*Config, error) {
-	// TODO allow to disable minify
+// Front matter values to apply to the templates within a path, declared under
+// the `defaults` key of config.yml, e.g.:
+//
+//	defaults:
+//	  - path: blog
+//	    values:
+//	      layout: post
+//	      lang: en
+//	  - path: "docs/**/*.md"
+//	    values:
+//	      layout: doc
+type FrontMatterDefault struct {
+	// a glob pattern or directory, relative to the src directory. Matches all templates if empty.
+	Path   string                 `yaml:"path"`
+	Values map[string]interface{} `yaml:"values"`
+}
 
-	config := &Config{
-		RootDir:          rootDir,
-		SrcDir:           filepath.Join(rootDir, "src"),
-		TargetDir:        filepath.Join(rootDir, "target"),
-		LayoutsDir:       filepath.Join(rootDir, "layouts"),
-		IncludesDir:      filepath.Join(rootDir, "includes"),
-		DataDir:          filepath.Join(rootDir, "data"),
-		PostFormat:       "blog/:title.org",
-		Lang:             "en",
-		HighlightTheme:   "github",
-		Minify:           true,
-		MinifyExclusions: make([]string, 0),
-		LiveReload:       false,
-		LinkStatic:       false,
-		IncludeDrafts:    false,
-		pageDefaults:     map[string]interface{}{},
+// Report whether the template at the given path, relative to the src directory,
+// should get these defaults.
+func (defaults FrontMatterDefault) Matches(relPath string) bool {
+	if defaults.Path == "" {
+		return true
 	}
+	return matchGlob(defaults.Path, relPath) || matchGlob(defaults.Path+"/**", relPath)
+}
 
-	// load overrides from config.yml
-	configPath := filepath.Join(rootDir, "config.yml")
-	yamlContent, err := os.ReadFile(configPath)
+// Report whether the file or directory at the given path, relative to the src directory,
+// should be left out of the site. Dot files, files matching an `exclude` pattern and files
+// within excluded directories are left out, unless they match an `include` pattern.
+func (config Config) IsExcluded(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	if relPath == "." {
+		return false
+	}
 
-	if errors.Is(err, os.ErrNotExist) {
-		// config file is not mandatory
-		return config, nil
-	} else if err != nil {
-		return nil, err
+	// a file within an excluded directory is excluded, regardless of its own patterns
+	if dir := path.Dir(relPath); dir != "." && config.IsExcluded(dir, true) {
+		return true
 	}
 
-	err = yaml.Unmarshal(yamlContent, &config.overrides)
-	if err != nil {
-		return nil, fmt.Errorf("invalid yaml format: File '%s', %w", configPath, err)
+	matchesAny := func(patterns []string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			return matchIgnorePattern(pattern, relPath, isDir)
+		})
 	}
+	excluded := strings.HasPrefix(path.Base(relPath), ".") || matchesAny(config.Exclude)
+	return excluded && !matchesAny(config.Include)
+}
 
-	// set user-provided overrides of declared config keys
-	// FIXME less copypasty way of declaring config overrides
-	if url, found := config.overrides["url"]; found {
-		config.SiteUrl = url.(string)
+// Set in the given template metadata the values of every default entry matching its path,
+// without overriding the ones already present. When several entries match, the latest one wins.
+func (config Config) ApplyDefaults(relPath string, metadata map[string]interface{}) {
+	explicit := maps.Clone(metadata)
+	for _, defaults := range config.Defaults {
+		if !defaults.Matches(relPath) {
+			continue
+		}
+		for key, value := range defaults.Values {
+			if _, found := explicit[key]; !found {
+				metadata[key] = value
+			}
+		}
 	}
-	if format, found := config.overrides["post_format"]; found {
-		config.PostFormat = format.(string)
+}
+
+// The environment variables prefix for config overrides, e.g. JORGE_URL sets the `url` key.
+const ENV_PREFIX = "JORGE_"
+
+// The environment variable that selects the config overlay, in addition to the --env flag.
+const ENV_VAR = "JORGE_ENV"
+
+// Load the project config for the given environment (e.g. production, staging), which selects
+// the config.<env>.yml overlay file. Values are taken, in increasing order of precedence,
+// from the defaults, config.yml, config.<env>.yml and JORGE_ environment variables.
+func Load(rootDir string, env string) (*Config, error) {
+	config := defaultConfig(rootDir, env)
+	if err := config.load(); err != nil {
+		return nil, err
 	}
-	if lang, found := config.overrides["lang"]; found {
-		config.Lang = lang.(string)
+	return config, nil
+}
+
+// Load the project config for the local development server. Dev-specific defaults (no minifying
+// or compressing, drafts included) can still be changed from the config files.
+// The given host and port override the config values, unless empty or zero.
+// With https, the site url uses that scheme. When listening on all interfaces, the site url
+// points to the first local network address instead, since the unspecified one isn't reachable.
+func LoadDev(rootDir string, env string, host string, port int, https bool, reload bool) (*Config, error) {
+	config := defaultConfig(rootDir, env)
+	config.Minify = false
+	config.Compress = false
+	config.IncludeDrafts = true
+	if err := config.load(); err != nil {
+		return nil, err
+	}
+
+	// setup serve command specific overrides
+	if host != "" {
+		config.ServerHost = host
 	}
-	if theme, found := config.overrides["highlight_theme"]; found {
-		config.HighlightTheme = theme.(string)
+	if port != 0 {
+		config.ServerPort = port
+	}
+	config.LiveReload = reload
+	config.LinkStatic = true
+	// errors are reported but shouldn't stop the server from reloading the rest of the site
+	config.KeepGoing = true
+	scheme := "http"
+	if https {
+		scheme = "https"
 	}
-	if exclusions, found := config.overrides["minify_exclusions"]; found {
-		for _, exclusion := range exclusions.([]interface{}) {
-			config.MinifyExclusions = append(config.MinifyExclusions, exclusion.(string))
+	urlHost := config.ServerHost
+	if ip := net.ParseIP(urlHost); ip != nil && ip.IsUnspecified() {
+		urlHost = "localhost"
+		if addrs := LocalAddresses(); len(addrs) > 0 {
+			urlHost = addrs[0]
 		}
 	}
+	config.SiteUrl = fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(urlHost, strconv.Itoa(config.ServerPort)))
 
 	return config, nil
 }
 
-func LoadDev(rootDir string, host string, port int, reload bool) (*Config, error) {
-	// TODO revisit is this Load vs LoadDevServer is the best way to handle both modes
-	// TODO some of the options need to be overridable: host, port, live reload at least
+// Return the ip addresses this machine can be reached at from the local network,
+// excluding the loopback and link-local ones.
+func LocalAddresses() []string {
+	var ips []string
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			ips = append(ips, ipNet.IP.String())
+		}
+	}
+	return ips
+}
 
-	config, err := Load(rootDir)
-	if err != nil {
-		return nil, err
+// Return the given path relative to the project root, unless it's absolute.
+func (config Config) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
 	}
+	return filepath.Join(config.RootDir, path)
+}
 
-	// setup serve command specific overrides (these could be eventually tweaked with flags)
-	config.ServerHost = host
-	config.ServerPort = port
-	config.LiveReload = reload
-	config.Minify = false
-	config.LinkStatic = true
-	config.IncludeDrafts = true
-	config.SiteUrl = fmt.Sprintf("http://%s:%d", config.ServerHost, config.ServerPort)
+func defaultConfig(rootDir string, env string) *Config {
+	return &Config{
+		RootDir:          rootDir,
+		SrcDir:           filepath.Join(rootDir, "src"),
+		TargetDir:        filepath.Join(rootDir, "target"),
+		LayoutsDir:       filepath.Join(rootDir, "layouts"),
+		IncludesDir:      filepath.Join(rootDir, "includes"),
+		DataDir:          filepath.Join(rootDir, "data"),
+		Env:              env,
+		PostFormat:       "blog/:title.org",
+		Lang:             "en",
+		HighlightTheme:   "github",
+		ServerHost:       "localhost",
+		ServerPort:       4001,
+		Minify:           true,
+		MinifyExclusions: make([]string, 0),
+		Compress:         false,
+		CompressMinSize:  1024,
+		LiveReload:       false,
+		LinkStatic:       false,
+		IncludeDrafts:    false,
+		Exclude:          make([]string, 0),
+		Include:          make([]string, 0),
+		Collections:      make([]Collection, 0),
+		Defaults:         make([]FrontMatterDefault, 0),
+		Deploy:           make([]DeployTarget, 0),
+		Redirects:        make(map[string]string),
+		Rewrites:         make([]Rewrite, 0),
+		Headers:          make([]ResponseHeaders, 0),
+		overrides:        make(map[string]interface{}),
+	}
+}
 
-	return config, nil
+// Return the paths of the config files that apply to the project, in the order they are loaded:
+// config.yml and the config.<env>.yml overlay, if there's an environment set.
+func (config Config) ConfigFiles() []string {
+	paths := []string{filepath.Join(config.RootDir, "config.yml")}
+	if config.Env != "" {
+		paths = append(paths, filepath.Join(config.RootDir, fmt.Sprintf("config.%s.yml", config.Env)))
+	}
+	return paths
+}
+
+// Apply the user config sources over the current values.
+func (config *Config) load() error {
+	for _, path := range config.ConfigFiles() {
+		// config files are not mandatory
+		if err := config.decodeFile(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return config.decodeEnvironment(os.Environ())
 }
 
 func (config Config) AsContext() map[string]interface{} {
 	context := map[string]interface{}{
 		"url": config.SiteUrl,
+		"env": config.Env,
 	}
 	maps.Copy(context, config.overrides)
 	return context
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTypedConfig(t *testing.T) {
+	rootDir := newConfig(`name: my site
+url: https://example.com
+lang: es
+destination: /tmp/out
+layouts_dir: ../shared/layouts
+minify_exclusions:
+  - feed.xml
+collections:
+  talks:
+    glob: "talks/*.md"
+    reverse: true
+`)
+	defer os.RemoveAll(rootDir)
+
+	config, err := Load(rootDir, "")
+	assertEqual(t, err, nil)
+	assertEqual(t, config.SiteUrl, "https://example.com")
+	assertEqual(t, config.Lang, "es")
+	assertEqual(t, config.PostFormat, "blog/:title.org")
+	assertEqual(t, len(config.MinifyExclusions), 1)
+	assertEqual(t, config.MinifyExclusions[0], "feed.xml")
+	assertEqual(t, len(config.Collections), 1)
+	assertEqual(t, config.Collections[0].Name, "talks")
+	assertEqual(t, config.Collections[0].SortBy, "path")
+	assertEqual(t, config.Collections[0].Reverse, true)
+	assertEqual(t, config.AsContext()["name"], "my site")
+	assertEqual(t, config.SrcDir, filepath.Join(rootDir, "src"))
+	assertEqual(t, config.TargetDir, "/tmp/out")
+	assertEqual(t, config.LayoutsDir, filepath.Join(rootDir, "..", "shared", "layouts"))
+
+	output, err := config.AsYAML()
+	assertEqual(t, err, nil)
+	assert(t, strings.Contains(string(output), "url: https://example.com\n"))
+	assert(t, strings.Contains(string(output), "talks:\n"))
+	assert(t, strings.HasSuffix(string(output), "name: my site\n"))
+}
+
+func TestLoadInvalidConfig(t *testing.T) {
+	tests := []struct {
+		content string
+		line    int
+		message string
+	}{
+		{"name: site\nurl: 123\n", 2, "'url' expected a string, got 123"},
+		{"url: example.com\n", 1, "'url' expected an absolute url such as https://example.com, got \"example.com\""},
+		{"lang: [en, es]\n", 1, "'lang' expected a string, got a list"},
+		{"source: \"\"\n", 1, "'source' expected a directory path"},
+		{"server_port: 70000\n", 1, "'server_port' expected a port number between 1 and 65535, got 70000"},
+		{"compress_min_size: -1\n", 1, "'compress_min_size' expected a size in bytes, got -1"},
+		{"minify_exclusions:\n  - feed.xml\n  - 3\n", 3, "'minify_exclusions' expected a list of strings, got 3"},
+		{"collections:\n  talks:\n    dir: talks\n    reverse: 1\n", 4, "'collections.talks.reverse' expected true or false, got 1"},
+		{"collections:\n  talks:\n    layout: talk\n", 3, "'collections.talks' needs either a dir or a glob"},
+		{"deploy:\n  site:\n    type: ftp\n", 3, "'deploy.site' unknown type 'ftp', expected local, rsync, git or s3"},
+		{"deploy:\n  site:\n    type: rsync\n    delete: yes\n", 4, "'deploy.site.delete' expected true or false, got \"yes\""},
+		{"deploy:\n  site:\n    type: rsync\n    delete: true\n", 3, "'deploy.site' rsync targets need a dest"},
+		{"redirects:\n  old/: /new/\n", 2, "'redirects' expected paths starting with /, got \"old/\""},
+		{"rewrites:\n  - from: \"app/**\"\n", 2, "'rewrites' expected rules with both from and to paths"},
+		{"defaults:\n  - path: \"[blog\"\n", 2, "'defaults' invalid glob pattern \"[blog\""},
+		{"name: [site\n", 1, "did not find expected ',' or ']'"},
+	}
+
+	for _, test := range tests {
+		rootDir := newConfig(test.content)
+		defer os.RemoveAll(rootDir)
+
+		_, err := Load(rootDir, "")
+		var configErr *ConfigError
+		assert(t, errors.As(err, &configErr))
+		assertEqual(t, configErr.Path, filepath.Join(rootDir, "config.yml"))
+		assertEqual(t, configErr.Line, test.line)
+		assertEqual(t, configErr.Message, test.message)
+	}
+}
+
+func TestLoadEnvironmentOverlay(t *testing.T) {
+	rootDir := newConfig(`name: my site
+url: https://example.com
+analytics:
+  id: 123
+  enabled: true
+collections:
+  talks:
+    dir: talks
+    layout: talk
+`)
+	defer os.RemoveAll(rootDir)
+	os.WriteFile(filepath.Join(rootDir, "config.staging.yml"), []byte(`url: https://staging.example.com
+drafts: true
+analytics:
+  enabled: false
+collections:
+  talks:
+    output: false
+`), 0666)
+
+	t.Setenv("JORGE_LANG", "es")
+	t.Setenv("JORGE_MINIFY", "false")
+	t.Setenv("JORGE_COLLECTIONS__TALKS__SORT_BY", "date")
+
+	// without env, only the base config and environment variables apply
+	config, err := Load(rootDir, "")
+	assertEqual(t, err, nil)
+	assertEqual(t, config.SiteUrl, "https://example.com")
+	assertEqual(t, config.IncludeDrafts, false)
+	assertEqual(t, config.Lang, "es")
+	assertEqual(t, config.Minify, false)
+
+	config, err = Load(rootDir, "staging")
+	assertEqual(t, err, nil)
+	assertEqual(t, config.Env, "staging")
+	assertEqual(t, config.SiteUrl, "https://staging.example.com")
+	assertEqual(t, config.IncludeDrafts, true)
+	assertEqual(t, config.Lang, "es")
+	assertEqual(t, config.Minify, false)
+	assertEqual(t, config.Collections[0].Dir, "talks")
+	assertEqual(t, config.Collections[0].Layout, "talk")
+	assertEqual(t, config.Collections[0].Output, false)
+	assertEqual(t, config.Collections[0].SortBy, "date")
+
+	context := config.AsContext()
+	assertEqual(t, context["env"], "staging")
+	analytics := context["analytics"].(map[string]interface{})
+	assertEqual(t, analytics["id"], 123)
+	assertEqual(t, analytics["enabled"], false)
+
+	// blank and comment-only values are empty strings
+	t.Setenv("JORGE_TITLE", "# x")
+	t.Setenv("JORGE_AUTHOR", "  ")
+	config, err = Load(rootDir, "")
+	assertEqual(t, err, nil)
+	assertEqual(t, config.AsContext()["title"], "")
+	assertEqual(t, config.AsContext()["author"], "")
+
+	// errors point to the variable that set the value
+	t.Setenv("JORGE_URL", "[https://example.com]")
+	_, err = Load(rootDir, "staging")
+	assertEqual(t, err.Error(), "invalid config: environment variable JORGE_URL: 'url' expected a string, got a list")
+}
+
+func TestIsExcluded(t *testing.T) {
+	config := Config{
+		Exclude: []string{"*.psd", "README.md", "notes/", "/drafts/*.md", "assets/**/*.map"},
+		Include: []string{".well-known", ".htaccess", "drafts/keep.md"},
+	}
+
+	assert(t, config.IsExcluded(".DS_Store", false))
+	assert(t, config.IsExcluded("blog/.post.org.swp", false))
+	assert(t, config.IsExcluded(".git", true))
+	assert(t, config.IsExcluded("images/cover.psd", false))
+	assert(t, config.IsExcluded("README.md", false))
+	assert(t, config.IsExcluded("blog/README.md", false))
+	assert(t, config.IsExcluded("notes", true))
+	assert(t, config.IsExcluded("notes/todo.txt", false))
+	assert(t, config.IsExcluded("drafts/post.md", false))
+	assert(t, config.IsExcluded("assets/js/vendor/app.js.map", false))
+
+	assert(t, !config.IsExcluded("blog/post.org", false))
+	assert(t, !config.IsExcluded("notes", false))
+	assert(t, !config.IsExcluded("blog/drafts/post.md", false))
+	assert(t, !config.IsExcluded("drafts/keep.md", false))
+	assert(t, !config.IsExcluded(".htaccess", false))
+	assert(t, !config.IsExcluded(".well-known", true))
+	assert(t, !config.IsExcluded(".well-known/security.txt", false))
+}
+
+func TestRoutingRules(t *testing.T) {
+	config := Config{
+		Rewrites: []Rewrite{{From: "app/**", To: "/app/index.html"}, {From: "docs/*", To: "/docs/missing.html"}},
+		Headers: []ResponseHeaders{
+			{Values: map[string]string{"X-Frame-Options": "DENY", "Cache-Control": "no-cache"}},
+			{Path: "assets/**", Values: map[string]string{"cache-control": "max-age=3600"}},
+		},
+	}
+
+	rewritten, found := config.RewritePath("/app/settings/profile")
+	assert(t, found)
+	assertEqual(t, rewritten, "/app/index.html")
+	rewritten, found = config.RewritePath("/docs/old-page")
+	assert(t, found)
+	assertEqual(t, rewritten, "/docs/missing.html")
+	_, found = config.RewritePath("/blog/post")
+	assert(t, !found)
+
+	headers := config.ResponseHeaders("/assets/css/main.css")
+	assertEqual(t, headers.Get("Cache-Control"), "max-age=3600")
+	assertEqual(t, headers.Get("X-Frame-Options"), "DENY")
+	headers = config.ResponseHeaders("/blog/post/")
+	assertEqual(t, headers.Get("Cache-Control"), "no-cache")
+}
+
+func TestSuggestConfigKey(t *testing.T) {
+	assertEqual(t, suggestConfigKey("post_fromat"), "post_format")
+	assertEqual(t, suggestConfigKey("highlightTheme"), "highlight_theme")
+	assertEqual(t, suggestConfigKey("urls"), "")
+	assertEqual(t, suggestConfigKey("author"), "")
+}
+
+func TestLoadDevSiteUrl(t *testing.T) {
+	rootDir := newConfig("url: https://example.com\n")
+	defer os.RemoveAll(rootDir)
+
+	config, err := LoadDev(rootDir, "", "", 0, false, true)
+	assertEqual(t, err, nil)
+	assertEqual(t, config.SiteUrl, "http://localhost:4001")
+	assertEqual(t, config.Minify, false)
+	assertEqual(t, config.Compress, false)
+	assertEqual(t, config.IncludeDrafts, true)
+
+	config, err = LoadDev(rootDir, "", "::1", 4002, true, true)
+	assertEqual(t, err, nil)
+	assertEqual(t, config.SiteUrl, "https://[::1]:4002")
+
+	// the unspecified address can't be browsed to, so a local one is used
+	for _, host := range []string{"0.0.0.0", "::"} {
+		config, err = LoadDev(rootDir, "", host, 0, true, true)
+		assertEqual(t, err, nil)
+		assertEqual(t, config.ServerHost, host)
+		expected := "https://localhost:4001"
+		if addrs := LocalAddresses(); len(addrs) > 0 {
+			expected = "https://" + net.JoinHostPort(addrs[0], "4001")
+		}
+		assertEqual(t, config.SiteUrl, expected)
+	}
+}
+
+// ------ HELPERS --------
+
+func newConfig(content string) string {
+	rootDir, _ := os.MkdirTemp("", "root")
+	os.WriteFile(filepath.Join(rootDir, "config.yml"), []byte(content), 0666)
+	return rootDir
+}
+
+func assert(t *testing.T, cond bool) {
+	t.Helper()
+	if !cond {
+		t.Fatalf("%v is false", cond)
+	}
+}
+
+func assertEqual(t *testing.T, a interface{}, b interface{}) {
+	t.Helper()
+	if a != b {
+		t.Fatalf("%v != %v", a, b)
+	}
+}
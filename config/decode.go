@@ -0,0 +1,543 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/facundoolano/jorge/internal/yamlerr"
+	"gopkg.in/yaml.v3"
+)
+
+// An invalid value found in a config file, pointing to its location.
+type ConfigError struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (err *ConfigError) Error() string {
+	if err.Line == 0 {
+		// values not coming from a file, e.g. environment variables
+		return fmt.Sprintf("invalid config: %s: %s", err.Path, err.Message)
+	}
+	return fmt.Sprintf("invalid config: File '%s', line %d: %s", err.Path, err.Line, err.Message)
+}
+
+// An error found while decoding a yaml node, before knowing what file it belongs to.
+// The key is the dotted path to the invalid value, relative to the node being decoded.
+type lineError struct {
+	line    int
+	key     string
+	message string
+}
+
+func (err *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", err.line, err.message)
+}
+
+// Return the error message prefixed with the full key of the invalid value.
+func (err *lineError) withKey(parent string) string {
+	key := parent
+	if err.key != "" {
+		key = parent + "." + err.key
+	}
+	if key == "" {
+		return err.message
+	}
+	return fmt.Sprintf("'%s' %s", key, err.message)
+}
+
+// A config.yml key that is decoded into a typed Config field.
+type configField struct {
+	key string
+	// return a pointer to the config field that the key value should be decoded into
+	target func(config *Config) interface{}
+	// an optional check of the decoded value
+	validate func(config *Config) error
+	// whether the value is a directory path, to be resolved relative to the project root
+	isDir bool
+}
+
+// The config.yml keys that have special meaning for jorge.
+// Keys not listed here are just passed as found to templates (site.config).
+var CONFIG_FIELDS = []configField{
+	{
+		key:    "source",
+		target: func(config *Config) interface{} { return &config.SrcDir },
+		isDir:  true,
+	},
+	{
+		key:    "destination",
+		target: func(config *Config) interface{} { return &config.TargetDir },
+		isDir:  true,
+	},
+	{
+		key:    "layouts_dir",
+		target: func(config *Config) interface{} { return &config.LayoutsDir },
+		isDir:  true,
+	},
+	{
+		key:    "includes_dir",
+		target: func(config *Config) interface{} { return &config.IncludesDir },
+		isDir:  true,
+	},
+	{
+		key:    "data_dir",
+		target: func(config *Config) interface{} { return &config.DataDir },
+		isDir:  true,
+	},
+	{
+		key:      "url",
+		target:   func(config *Config) interface{} { return &config.SiteUrl },
+		validate: validateUrl,
+	},
+	{
+		key:    "post_format",
+		target: func(config *Config) interface{} { return &config.PostFormat },
+	},
+	{
+		key:    "lang",
+		target: func(config *Config) interface{} { return &config.Lang },
+	},
+	{
+		key:    "highlight_theme",
+		target: func(config *Config) interface{} { return &config.HighlightTheme },
+	},
+	{
+		key:    "minify",
+		target: func(config *Config) interface{} { return &config.Minify },
+	},
+	{
+		key:    "minify_exclusions",
+		target: func(config *Config) interface{} { return &config.MinifyExclusions },
+	},
+	{
+		key:    "compress",
+		target: func(config *Config) interface{} { return &config.Compress },
+	},
+	{
+		key:      "compress_min_size",
+		target:   func(config *Config) interface{} { return &config.CompressMinSize },
+		validate: validateCompressMinSize,
+	},
+	{
+		key:    "server_host",
+		target: func(config *Config) interface{} { return &config.ServerHost },
+	},
+	{
+		key:      "server_port",
+		target:   func(config *Config) interface{} { return &config.ServerPort },
+		validate: validatePort,
+	},
+	{
+		key:    "drafts",
+		target: func(config *Config) interface{} { return &config.IncludeDrafts },
+	},
+	{
+		key:      "exclude",
+		target:   func(config *Config) interface{} { return &config.Exclude },
+		validate: func(config *Config) error { return validateGlobs(config.Exclude) },
+	},
+	{
+		key:      "include",
+		target:   func(config *Config) interface{} { return &config.Include },
+		validate: func(config *Config) error { return validateGlobs(config.Include) },
+	},
+	{
+		key:    "collections",
+		target: func(config *Config) interface{} { return (*collectionList)(&config.Collections) },
+	},
+	{
+		key:      "defaults",
+		target:   func(config *Config) interface{} { return &config.Defaults },
+		validate: validateDefaults,
+	},
+	{
+		key:      "redirects",
+		target:   func(config *Config) interface{} { return &config.Redirects },
+		validate: validateRedirects,
+	},
+	{
+		key:      "rewrites",
+		target:   func(config *Config) interface{} { return &config.Rewrites },
+		validate: validateRewrites,
+	},
+	{
+		key:      "headers",
+		target:   func(config *Config) interface{} { return &config.Headers },
+		validate: validateHeaders,
+	},
+	{
+		key:    "deploy",
+		target: func(config *Config) interface{} { return (*deployTargetList)(&config.Deploy) },
+	},
+}
+
+// Read the yaml file at the given path, decoding the known keys into the typed config fields
+// and adding the rest to the config overrides. Values found in the file are deep-merged over the
+// ones already set in the config: mappings are merged key by key, other values are replaced.
+// Returns os.ErrNotExist if the file is missing.
+func (config *Config) decodeFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		line, message := yamlerr.Split(err)
+		return &ConfigError{Path: path, Line: line, Message: message}
+	}
+	if len(document.Content) == 0 {
+		// empty file
+		return nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return &ConfigError{Path: path, Line: root.Line, Message: "expected a mapping of keys to values"}
+	}
+	return config.decodeMapping(path, root)
+}
+
+// Decode the keys of the given yaml mapping node into the config. The path is used for error reporting.
+func (config *Config) decodeMapping(path string, root *yaml.Node) error {
+	var overrides map[string]interface{}
+	if err := root.Decode(&overrides); err != nil {
+		line, message := yamlerr.Split(err)
+		return &ConfigError{Path: path, Line: line, Message: message}
+	}
+	mergeMaps(config.overrides, overrides)
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valueNode := root.Content[i], root.Content[i+1]
+		field := findConfigField(keyNode.Value)
+		if field == nil {
+			if suggestion := suggestConfigKey(keyNode.Value); suggestion != "" {
+				location := path
+				if keyNode.Line != 0 {
+					location = fmt.Sprintf("%s:%d", path, keyNode.Line)
+				}
+				fmt.Fprintf(os.Stderr, "warning: %s: unknown config key '%s', did you mean '%s'?\n",
+					location, keyNode.Value, suggestion)
+			}
+			continue
+		}
+
+		if err := decodeValue(valueNode, field.target(config)); err != nil {
+			var lineErr *lineError
+			if errors.As(err, &lineErr) {
+				return &ConfigError{Path: path, Line: lineErr.line, Message: lineErr.withKey(field.key)}
+			}
+			return &ConfigError{Path: path, Line: valueNode.Line, Message: fmt.Sprintf("'%s' %s", field.key, err)}
+		}
+		if field.isDir {
+			dir := field.target(config).(*string)
+			if *dir == "" {
+				return &ConfigError{Path: path, Line: valueNode.Line, Message: fmt.Sprintf("'%s' expected a directory path", field.key)}
+			}
+			*dir = config.resolvePath(*dir)
+		}
+		if field.validate != nil {
+			if err := field.validate(config); err != nil {
+				return &ConfigError{Path: path, Line: valueNode.Line, Message: fmt.Sprintf("'%s' %s", field.key, err)}
+			}
+		}
+	}
+	return nil
+}
+
+// Apply the JORGE_ prefixed variables from the given environment as config overrides.
+// The variable name, lowercased, is the config key, with double underscores separating nested
+// keys (e.g. JORGE_COLLECTIONS__TALKS__OUTPUT=false). Values are parsed as yaml.
+func (config *Config) decodeEnvironment(environ []string) error {
+	// sort to get a predictable result when several variables set the same key
+	environ = slices.Clone(environ)
+	slices.Sort(environ)
+
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, ENV_PREFIX) || name == ENV_VAR {
+			continue
+		}
+		source := "environment variable " + name
+
+		// blank values, including the ones with only a yaml comment, are taken as empty strings
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: ""}
+		if value != "" {
+			var document yaml.Node
+			if err := yaml.Unmarshal([]byte(value), &document); err != nil {
+				_, message := yamlerr.Split(err)
+				return &ConfigError{Path: source, Message: message}
+			}
+			if len(document.Content) > 0 {
+				node = document.Content[0]
+				clearLines(node)
+			}
+		}
+
+		keys := strings.Split(strings.ToLower(strings.TrimPrefix(name, ENV_PREFIX)), "__")
+		for i := len(keys) - 1; i >= 0; i-- {
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keys[i]}
+			node = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{keyNode, node}}
+		}
+		if err := config.decodeMapping(source, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove the line numbers of the given node and its children, so they are not reported in errors
+// of values that don't come from a file.
+func clearLines(node *yaml.Node) {
+	node.Line = 0
+	for _, child := range node.Content {
+		clearLines(child)
+	}
+}
+
+// Recursively copy the values of src into dst, merging the nested maps present in both.
+func mergeMaps(dst map[string]interface{}, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+		} else {
+			dst[key] = value
+		}
+	}
+}
+
+func findConfigField(key string) *configField {
+	for i := range CONFIG_FIELDS {
+		if CONFIG_FIELDS[i].key == key {
+			return &CONFIG_FIELDS[i]
+		}
+	}
+	return nil
+}
+
+// Decode the given node into the target pointer, checking that scalar values have the expected type
+// (yaml would otherwise silently convert e.g. numbers to strings).
+// Returns a *lineError pointing to the invalid value on failure.
+func decodeValue(node *yaml.Node, target interface{}) error {
+	invalid := func(node *yaml.Node, expected string) error {
+		return &lineError{node.Line, "", fmt.Sprintf("expected %s, got %s", expected, describeNode(node))}
+	}
+
+	switch target.(type) {
+	case *string:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!str" {
+			return invalid(node, "a string")
+		}
+	case *bool:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!bool" {
+			return invalid(node, "true or false")
+		}
+	case *int:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!int" {
+			return invalid(node, "an integer")
+		}
+	case *[]string:
+		if node.Kind != yaml.SequenceNode {
+			return invalid(node, "a list of strings")
+		}
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode || item.ShortTag() != "!!str" {
+				return invalid(item, "a list of strings")
+			}
+		}
+	case *map[string]string:
+		if node.Kind != yaml.MappingNode {
+			return invalid(node, "a mapping of strings")
+		}
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode || item.ShortTag() != "!!str" {
+				return invalid(item, "a mapping of strings")
+			}
+		}
+	}
+
+	if err := node.Decode(target); err != nil {
+		var lineErr *lineError
+		if errors.As(err, &lineErr) {
+			return err
+		}
+		line, message := yamlerr.Split(err)
+		if line == 0 {
+			line = node.Line
+		}
+		return &lineError{line, "", message}
+	}
+	return nil
+}
+
+// Check that the values of the given named settings mapping are strings, except for the given boolean keys.
+func checkSettingTypes(name string, settings *yaml.Node, boolKeys ...string) error {
+	for i := 0; i+1 < len(settings.Content); i += 2 {
+		key, value := settings.Content[i].Value, settings.Content[i+1]
+		var err error
+		if slices.Contains(boolKeys, key) {
+			err = decodeValue(value, new(bool))
+		} else {
+			err = decodeValue(value, new(string))
+		}
+		var lineErr *lineError
+		if errors.As(err, &lineErr) {
+			lineErr.key = name + "." + key
+			return lineErr
+		}
+	}
+	return nil
+}
+
+// Return a short description of the node value for error messages.
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return "an empty value"
+		case "!!str":
+			return strconv.Quote(node.Value)
+		}
+		return node.Value
+	}
+	return "an invalid value"
+}
+
+// Return the known config key most similar to the given one, if it looks like a misspelling of it.
+func suggestConfigKey(key string) string {
+	normalize := func(key string) string {
+		return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+	}
+
+	for _, field := range CONFIG_FIELDS {
+		distance := levenshtein(normalize(key), normalize(field.key))
+		if distance <= 2 && distance < len(field.key)/2 {
+			return field.key
+		}
+	}
+	return ""
+}
+
+// Compute the edit distance between two strings.
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func validateUrl(config *Config) error {
+	if config.SiteUrl == "" {
+		return nil
+	}
+	parsed, err := url.Parse(config.SiteUrl)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		return fmt.Errorf("expected an absolute url such as https://example.com, got %s", strconv.Quote(config.SiteUrl))
+	}
+	return nil
+}
+
+func validatePort(config *Config) error {
+	if config.ServerPort < 1 || config.ServerPort > 65535 {
+		return fmt.Errorf("expected a port number between 1 and 65535, got %d", config.ServerPort)
+	}
+	return nil
+}
+
+func validateCompressMinSize(config *Config) error {
+	if config.CompressMinSize < 0 {
+		return fmt.Errorf("expected a size in bytes, got %d", config.CompressMinSize)
+	}
+	return nil
+}
+
+func validateGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if err := validateGlob(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateDefaults(config *Config) error {
+	for _, defaults := range config.Defaults {
+		if err := validateGlob(defaults.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return an error if the given pattern is not a valid glob.
+func validateGlob(pattern string) error {
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if _, err := filepath.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %s", strconv.Quote(pattern))
+		}
+	}
+	return nil
+}
+
+// Return the config as it results from applying the defaults, config file and cli flags,
+// formatted as yaml: first the known keys, then the custom ones sorted alphabetically.
+func (config Config) AsYAML() ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	addKey := func(key string, value interface{}) error {
+		var valueNode yaml.Node
+		if err := valueNode.Encode(value); err != nil {
+			return err
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &valueNode)
+		return nil
+	}
+
+	for _, field := range CONFIG_FIELDS {
+		if err := addKey(field.key, field.target(&config)); err != nil {
+			return nil, err
+		}
+	}
+
+	customKeys := make([]string, 0)
+	for key := range config.overrides {
+		if findConfigField(key) == nil {
+			customKeys = append(customKeys, key)
+		}
+	}
+	slices.Sort(customKeys)
+	for _, key := range customKeys {
+		if err := addKey(key, config.overrides[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	return yaml.Marshal(root)
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The kinds of destinations a site can be deployed to, and the settings each of them requires.
+var DEPLOY_TYPES = map[string][]string{
+	"local": {"dir"},
+	"rsync": {"dest"},
+	"git":   {"branch"},
+	"s3":    {"bucket"},
+}
+
+// A named destination to publish the built site to, declared under the `deploy` key
+// of config.yml, e.g.:
+//
+//	deploy:
+//	  production:
+//	    type: rsync
+//	    dest: user@example.com:/var/www/site
+//	    delete: true
+//	  pages:
+//	    type: git
+//	    branch: gh-pages
+//	    remote: origin
+//	  bucket:
+//	    type: s3
+//	    bucket: my-site
+//	    endpoint: http://localhost:9000
+type DeployTarget struct {
+	Name string `yaml:"-"`
+
+	// one of local, rsync, git or s3.
+	Type string `yaml:"type"`
+
+	// whether to remove the files at the destination that are no longer part of the site.
+	Delete bool `yaml:"delete"`
+
+	// local: the directory to mirror the site to, relative to the project root.
+	Dir string `yaml:"dir,omitempty"`
+
+	// rsync: the destination in rsync format, e.g. user@host:/var/www/site.
+	Dest string `yaml:"dest,omitempty"`
+
+	// git: the branch to commit the site files to, the repository to commit them in
+	// (the project root by default) and an optional remote to push the branch to.
+	Branch  string `yaml:"branch,omitempty"`
+	Repo    string `yaml:"repo,omitempty"`
+	Remote  string `yaml:"remote,omitempty"`
+	Message string `yaml:"message,omitempty"`
+
+	// s3: the bucket to upload the files to, an optional key prefix, and the region and
+	// endpoint of the service, which can point to S3-compatible storage.
+	// Credentials are taken from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables.
+	Bucket   string `yaml:"bucket,omitempty"`
+	Prefix   string `yaml:"prefix,omitempty"`
+	Region   string `yaml:"region,omitempty"`
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// Return the deploy target with the given name, or the first declared one if the name is empty.
+func (config Config) DeployTarget(name string) (*DeployTarget, error) {
+	if len(config.Deploy) == 0 {
+		return nil, fmt.Errorf("no deploy targets declared in config.yml")
+	}
+	if name == "" {
+		return &config.Deploy[0], nil
+	}
+	for _, target := range config.Deploy {
+		if target.Name == name {
+			return &target, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown deploy target '%s'", name)
+}
+
+// The deploy targets as declared in config.yml: a mapping of names to target settings.
+type deployTargetList []DeployTarget
+
+// Decode the deploy targets mapping, keeping the declaration order. As with collections,
+// the settings of targets already in the list are merged with the decoded ones.
+func (targets *deployTargetList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return &lineError{node.Line, "", fmt.Sprintf("expected a mapping of names to settings, got %s", describeNode(node))}
+	}
+
+	decoded := slices.Clone(*targets)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, settings := node.Content[i].Value, node.Content[i+1]
+		if settings.Kind != yaml.MappingNode {
+			return &lineError{settings.Line, name, fmt.Sprintf("expected a mapping of settings, got %s", describeNode(settings))}
+		}
+		if err := checkSettingTypes(name, settings, "delete"); err != nil {
+			return err
+		}
+
+		index := slices.IndexFunc(decoded, func(target DeployTarget) bool {
+			return target.Name == name
+		})
+		if index == -1 {
+			decoded = append(decoded, DeployTarget{Name: name})
+			index = len(decoded) - 1
+		}
+		target := &decoded[index]
+		if err := settings.Decode(target); err != nil {
+			return err
+		}
+
+		required, ok := DEPLOY_TYPES[target.Type]
+		if !ok {
+			return &lineError{settings.Line, name, fmt.Sprintf("unknown type '%s', expected local, rsync, git or s3", target.Type)}
+		}
+		values := map[string]string{"dir": target.Dir, "dest": target.Dest, "branch": target.Branch, "bucket": target.Bucket}
+		for _, key := range required {
+			if values[key] == "" {
+				return &lineError{settings.Line, name, fmt.Sprintf("%s targets need a %s", target.Type, key)}
+			}
+		}
+	}
+	*targets = decoded
+	return nil
+}
+
+// Encode the deploy targets back to a mapping of names to settings.
+func (targets deployTargetList) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, target := range targets {
+		var settings yaml.Node
+		if err := settings.Encode(target); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: target.Name}, &settings)
+	}
+	return node, nil
+}
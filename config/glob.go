@@ -0,0 +1,57 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Report whether the given slash-separated path matches the glob pattern.
+// Patterns follow the filepath.Match syntax for each path segment, with the addition
+// of `**` segments, which match any number of directories (including none).
+func matchGlob(pattern string, path string) bool {
+	pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern []string, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// try to match the rest of the pattern at every remaining depth
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if matched, _ := filepath.Match(pattern[0], path[0]); !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		path = path[1:]
+	}
+	return len(path) == 0
+}
+
+// Report whether the given slash-separated path matches a gitignore-style pattern:
+// patterns without a slash (other than a trailing one) match the file name at any depth,
+// the rest are matched against the whole path. Patterns with a trailing slash only match directories.
+func matchIgnorePattern(pattern string, path string, isDir bool) bool {
+	pattern = filepath.ToSlash(pattern)
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+
+	if strings.Contains(pattern, "/") {
+		return matchGlob(pattern, path)
+	}
+	matched, _ := filepath.Match(pattern, filepath.Base(filepath.FromSlash(path)))
+	return matched
+}
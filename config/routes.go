@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// A rule to serve the files at one path for the requests to others, declared under the
+// `rewrites` key of config.yml, e.g. to serve a single page app for all of its routes:
+//
+//	rewrites:
+//	  - from: "app/**"
+//	    to: /app/index.html
+//
+// Rewrites only apply to the requests that don't match a site file, like the nginx try_files fallback.
+// Rewrites are applied by the development server, the production one needs equivalent rules.
+type Rewrite struct {
+	// a glob pattern matched against the request path
+	From string `yaml:"from"`
+	// the path of the file to serve instead
+	To string `yaml:"to"`
+}
+
+// Custom headers to add to the responses for the paths matching a glob pattern, declared
+// under the `headers` key of config.yml, e.g.:
+//
+//	headers:
+//	  - path: "assets/**"
+//	    values:
+//	      Cache-Control: max-age=3600
+//
+// As with rewrites, they are only applied by the development server.
+type ResponseHeaders struct {
+	// a glob pattern matched against the request path. Matches all paths if empty.
+	Path   string            `yaml:"path"`
+	Values map[string]string `yaml:"values"`
+}
+
+// Return the path to serve instead of the given url path, according to the first matching rewrite.
+func (config Config) RewritePath(urlPath string) (string, bool) {
+	for _, rewrite := range config.Rewrites {
+		if matchGlob(rewrite.From, urlPath) {
+			return rewrite.To, true
+		}
+	}
+	return "", false
+}
+
+// Return the custom headers for the responses to the given url path.
+// Values from later matching rules override earlier ones.
+func (config Config) ResponseHeaders(urlPath string) http.Header {
+	headers := make(http.Header)
+	for _, rule := range config.Headers {
+		if rule.Path != "" && !matchGlob(rule.Path, urlPath) {
+			continue
+		}
+		for name, value := range rule.Values {
+			headers.Set(name, value)
+		}
+	}
+	return headers
+}
+
+func validateRedirects(config *Config) error {
+	for from, to := range config.Redirects {
+		if !strings.HasPrefix(from, "/") {
+			return fmt.Errorf("expected paths starting with /, got %s", strconv.Quote(from))
+		}
+		if to == "" {
+			return fmt.Errorf("missing destination for %s", strconv.Quote(from))
+		}
+	}
+	return nil
+}
+
+func validateRewrites(config *Config) error {
+	for _, rewrite := range config.Rewrites {
+		if rewrite.From == "" || rewrite.To == "" {
+			return fmt.Errorf("expected rules with both from and to paths")
+		}
+		if err := validateGlob(rewrite.From); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateHeaders(config *Config) error {
+	for _, rule := range config.Headers {
+		if err := validateGlob(rule.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package deploy
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+// A destination where the files of a built site can be published.
+type Target interface {
+	// Publish the contents of the given directory, transferring only the files that changed
+	// since the previous deploy.
+	Deploy(siteDir string) (*Result, error)
+}
+
+// Create a target from the settings of a config.yml deploy entry.
+type constructor func(config config.Config, settings config.DeployTarget) (Target, error)
+
+var constructors = map[string]constructor{
+	"local": newLocalTarget,
+	"rsync": newRsyncTarget,
+	"git":   newGitTarget,
+	"s3":    newS3Target,
+}
+
+// The outcome of a deploy. Paths are slash-separated and relative to the site directory.
+type Result struct {
+	Uploaded  []string
+	Deleted   []string
+	Unchanged int
+}
+
+// Return the target for the given deploy settings.
+func New(config config.Config, settings config.DeployTarget) (Target, error) {
+	create, ok := constructors[settings.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown deploy target type '%s'", settings.Type)
+	}
+	return create(config, settings)
+}
+
+// Print the transferred files and a summary of the deploy.
+func (result *Result) Print(out io.Writer) {
+	for _, relPath := range result.Uploaded {
+		fmt.Fprintln(out, "uploaded", relPath)
+	}
+	for _, relPath := range result.Deleted {
+		fmt.Fprintln(out, "deleted ", relPath)
+	}
+	fmt.Fprintf(out, "%d uploaded, %d deleted, %d unchanged\n", len(result.Uploaded), len(result.Deleted), result.Unchanged)
+}
+
+func (result *Result) sort() {
+	slices.Sort(result.Uploaded)
+	slices.Sort(result.Deleted)
+}
+
+func newResult() *Result {
+	return &Result{Uploaded: make([]string, 0), Deleted: make([]string, 0)}
+}
+
+// Return the regular files within the given directory, mapping their slash-separated
+// relative paths to their full paths. Symlinks are followed, in case the directory
+// was built by the dev server. A missing directory has no files.
+func listFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && filePath == dir {
+			return filepath.SkipAll
+		} else if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		relPath, _ := filepath.Rel(dir, filePath)
+		files[filepath.ToSlash(relPath)] = filePath
+		return nil
+	})
+	return files, err
+}
+
+// Return the hex-encoded hash of the file contents.
+func hashFile(path string, hash hash.Hash) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func sha256File(path string) (string, error) {
+	return hashFile(path, sha256.New())
+}
+
+func md5File(path string) (string, error) {
+	return hashFile(path, md5.New())
+}
+
+// Resolve a path from the deploy settings relative to the project root.
+func resolvePath(config config.Config, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(config.RootDir, path)
+}
@@ -0,0 +1,173 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+const DEFAULT_COMMIT_MESSAGE = "Deploy site"
+
+// Commits the site files to a branch of a git repository, e.g. gh-pages,
+// without touching the repository working tree, and optionally pushes it.
+type gitTarget struct {
+	repo    string
+	branch  string
+	remote  string
+	message string
+	delete  bool
+}
+
+func newGitTarget(config config.Config, settings config.DeployTarget) (Target, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git deploy target '%s' requires the git command: %w", settings.Name, err)
+	}
+	repo := config.RootDir
+	if settings.Repo != "" {
+		repo = resolvePath(config, settings.Repo)
+	}
+	message := settings.Message
+	if message == "" {
+		message = DEFAULT_COMMIT_MESSAGE
+	}
+	return &gitTarget{
+		repo:    repo,
+		branch:  settings.Branch,
+		remote:  settings.Remote,
+		message: message,
+		delete:  settings.Delete,
+	}, nil
+}
+
+// Build a commit with the site files on top of the branch, using a temporary index so
+// the repository working tree and staging area are left alone. Git compares files by
+// their content hashes, so only the changed ones end up in the commit.
+func (target *gitTarget) Deploy(siteDir string) (*Result, error) {
+	siteDir, err := filepath.Abs(siteDir)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.CreateTemp("", "jorge-deploy-index-*")
+	if err != nil {
+		return nil, err
+	}
+	index.Close()
+	// git expects either a valid index file or no file at all
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+	git := func(args ...string) (string, error) {
+		return target.git(index.Name(), args...)
+	}
+
+	ref := "refs/heads/" + target.branch
+	parent, err := git("rev-parse", "--verify", "--quiet", ref)
+	if err != nil {
+		// the branch doesn't exist yet
+		parent = ""
+	}
+
+	if parent != "" {
+		if _, err := git("read-tree", parent); err != nil {
+			return nil, err
+		}
+	}
+	// without delete, files missing from the site are kept from the previous commit
+	addMode := "--ignore-removal"
+	if target.delete {
+		addMode = "--all"
+	}
+	if _, err := git("--work-tree", siteDir, "add", "--force", addMode, ":/"); err != nil {
+		return nil, err
+	}
+	tree, err := git("write-tree")
+	if err != nil {
+		return nil, err
+	}
+
+	result := newResult()
+	files, err := git("ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+	fileCount := len(splitNull(files))
+	if parent == "" {
+		result.Uploaded = splitNull(files)
+	} else {
+		// a sequence of status and path pairs
+		changes, err := git("diff-index", "--cached", "--name-status", "--no-renames", "-z", parent)
+		if err != nil {
+			return nil, err
+		}
+		fields := splitNull(changes)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] == "D" {
+				result.Deleted = append(result.Deleted, fields[i+1])
+			} else {
+				result.Uploaded = append(result.Uploaded, fields[i+1])
+			}
+		}
+	}
+	result.Unchanged = fileCount - len(result.Uploaded)
+	result.sort()
+
+	if len(result.Uploaded)+len(result.Deleted) > 0 {
+		if err := commitTree(git, ref, parent, tree, target.message); err != nil {
+			return nil, err
+		}
+	}
+
+	if target.remote != "" {
+		// push even if there was nothing new to commit, in case a previous push failed
+		if _, err := git("push", target.remote, ref+":"+ref); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Commit the given tree on top of the parent commit, if any, and point the branch ref to it.
+func commitTree(git func(args ...string) (string, error), ref string, parent string, tree string, message string) error {
+	commitArgs := []string{"commit-tree", tree, "-m", message}
+	if parent != "" {
+		commitArgs = append(commitArgs, "-p", parent)
+	}
+	commit, err := git(commitArgs...)
+	if err != nil {
+		return err
+	}
+	updateArgs := []string{"update-ref", ref, commit}
+	if parent != "" {
+		// fail if the branch moved in the meantime
+		updateArgs = append(updateArgs, parent)
+	}
+	_, err = git(updateArgs...)
+	return err
+}
+
+// Run a git command in the target repository with the given index file,
+// returning its trimmed output.
+func (target *gitTarget) git(indexFile string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", target.repo}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+indexFile)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w\n%s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Split NUL-separated git output.
+func splitNull(output string) []string {
+	output = strings.TrimSuffix(output, "\x00")
+	if output == "" {
+		return nil
+	}
+	return strings.Split(output, "\x00")
+}
@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+const FILE_RW_MODE = 0666
+const DIR_RWE_MODE = 0777
+
+// Mirrors the site to a directory in the local filesystem, e.g. a mounted volume.
+type localTarget struct {
+	dir    string
+	delete bool
+}
+
+func newLocalTarget(config config.Config, settings config.DeployTarget) (Target, error) {
+	dir := resolvePath(config, settings.Dir)
+
+	// the files in the directory may be deleted or overwritten, so it can't hold the site sources
+	abs, _ := filepath.Abs(dir)
+	root, _ := filepath.Abs(config.RootDir)
+	src, _ := filepath.Abs(config.SrcDir)
+	target, _ := filepath.Abs(config.TargetDir)
+	if isWithin(abs, root) || isWithin(abs, src) || isWithin(src, abs) || isWithin(abs, target) || isWithin(target, abs) {
+		return nil, fmt.Errorf("invalid deploy directory '%s': it can't contain the project or overlap with the source or target directories", settings.Dir)
+	}
+	return &localTarget{dir: dir, delete: settings.Delete}, nil
+}
+
+// Copy the files whose contents differ from the ones at the destination directory,
+// comparing their sha256 hashes.
+func (target *localTarget) Deploy(siteDir string) (*Result, error) {
+	files, err := listFiles(siteDir)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := listFiles(target.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newResult()
+	for relPath, srcPath := range files {
+		if destPath, found := existing[relPath]; found {
+			srcHash, err := sha256File(srcPath)
+			if err != nil {
+				return nil, err
+			}
+			destHash, err := sha256File(destPath)
+			if err != nil {
+				return nil, err
+			}
+			if srcHash == destHash {
+				result.Unchanged++
+				continue
+			}
+		}
+
+		if err := copyFile(srcPath, filepath.Join(target.dir, filepath.FromSlash(relPath))); err != nil {
+			return nil, err
+		}
+		result.Uploaded = append(result.Uploaded, relPath)
+	}
+
+	if target.delete {
+		for relPath, destPath := range existing {
+			if _, found := files[relPath]; found {
+				continue
+			}
+			if err := os.Remove(destPath); err != nil {
+				return nil, err
+			}
+			removeEmptyDirs(target.dir, filepath.Dir(destPath))
+			result.Deleted = append(result.Deleted, relPath)
+		}
+	}
+
+	result.sort()
+	return result, nil
+}
+
+func copyFile(srcPath string, destPath string) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), DIR_RWE_MODE); err != nil {
+		return err
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// remove first in case the destination is a symlink, to avoid writing to the file it points to
+	os.Remove(destPath)
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FILE_RW_MODE)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
+
+// Report whether path is dir or is located inside of it.
+func isWithin(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Remove the given directory and its parents, up to the root, as long as they are empty.
+func removeEmptyDirs(root string, dir string) {
+	for dir != root && len(dir) > len(root) {
+		if os.Remove(dir) != nil {
+			// not empty
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+// Syncs the site to a remote host using rsync over ssh.
+type rsyncTarget struct {
+	dest   string
+	delete bool
+}
+
+func newRsyncTarget(config config.Config, settings config.DeployTarget) (Target, error) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		return nil, fmt.Errorf("rsync deploy target '%s' requires the rsync command: %w", settings.Name, err)
+	}
+	return &rsyncTarget{dest: settings.Dest, delete: settings.Delete}, nil
+}
+
+// Run rsync comparing file checksums instead of modification times,
+// which change on every build.
+func (target *rsyncTarget) Deploy(siteDir string) (*Result, error) {
+	args := []string{"--recursive", "--copy-links", "--compress", "--checksum", "--itemize-changes", "--rsh", "ssh"}
+	if target.delete {
+		args = append(args, "--delete")
+	}
+	args = append(args, strings.TrimSuffix(siteDir, string(os.PathSeparator))+"/", target.dest)
+
+	var stdout bytes.Buffer
+	cmd := exec.Command("rsync", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("rsync failed: %w", err)
+	}
+
+	files, err := listFiles(siteDir)
+	if err != nil {
+		return nil, err
+	}
+	result := parseItemizedChanges(stdout.String())
+	result.Unchanged = len(files) - len(result.Uploaded)
+	result.sort()
+	return result, nil
+}
+
+// Extract the transferred and deleted files from the output of rsync --itemize-changes.
+func parseItemizedChanges(output string) *Result {
+	result := newResult()
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		changes, relPath, found := strings.Cut(line, " ")
+		if !found {
+			continue
+		}
+		if changes == "*deleting" {
+			relPath = strings.TrimSpace(relPath)
+			if !strings.HasSuffix(relPath, "/") {
+				result.Deleted = append(result.Deleted, relPath)
+			}
+		} else if strings.HasPrefix(changes, "<f") {
+			result.Uploaded = append(result.Uploaded, relPath)
+		}
+	}
+	return result
+}
@@ -0,0 +1,331 @@
+package deploy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+const DEFAULT_S3_REGION = "us-east-1"
+
+// sha256 of an empty payload, sent with requests that have no body
+const EMPTY_PAYLOAD_HASH = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Uploads the site to a bucket of S3 or a compatible service (e.g. MinIO),
+// signing the requests with AWS Signature Version 4.
+type s3Target struct {
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	delete    bool
+	client    *http.Client
+}
+
+func newS3Target(config config.Config, settings config.DeployTarget) (Target, error) {
+	region := settings.Region
+	if region == "" {
+		region = DEFAULT_S3_REGION
+	}
+	endpoint := settings.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil || endpointUrl.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint for s3 deploy target '%s': %s", settings.Name, endpoint)
+	}
+
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("s3 deploy target '%s' requires the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables", settings.Name)
+	}
+
+	prefix := strings.Trim(settings.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &s3Target{
+		endpoint:  endpointUrl,
+		bucket:    settings.Bucket,
+		prefix:    prefix,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		delete:    settings.Delete,
+		client:    http.DefaultClient,
+	}, nil
+}
+
+// Upload the files whose md5 hash differs from the ETag of the object at the bucket.
+// This assumes objects were uploaded in a single part, as this target does.
+func (target *s3Target) Deploy(siteDir string) (*Result, error) {
+	files, err := listFiles(siteDir)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := target.listObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	result := newResult()
+	for relPath, srcPath := range files {
+		hash, err := md5File(srcPath)
+		if err != nil {
+			return nil, err
+		}
+		if etag, found := objects[target.prefix+relPath]; found && etag == hash {
+			result.Unchanged++
+			continue
+		}
+		if err := target.putObject(target.prefix+relPath, srcPath); err != nil {
+			return nil, err
+		}
+		result.Uploaded = append(result.Uploaded, relPath)
+	}
+
+	if target.delete {
+		for key := range objects {
+			relPath := strings.TrimPrefix(key, target.prefix)
+			if _, found := files[relPath]; found {
+				continue
+			}
+			if err := target.deleteObject(key); err != nil {
+				return nil, err
+			}
+			result.Deleted = append(result.Deleted, relPath)
+		}
+	}
+
+	result.sort()
+	return result, nil
+}
+
+// The subset of the ListObjectsV2 response used to compare the bucket contents.
+type listBucketResult struct {
+	Contents []struct {
+		Key  string
+		ETag string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// Return the ETags of the objects under the target prefix, keyed by object key.
+func (target *s3Target) listObjects() (map[string]string, error) {
+	objects := make(map[string]string)
+	query := url.Values{"list-type": {"2"}, "prefix": {target.prefix}}
+	for {
+		response, err := target.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket listing: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			objects[object.Key] = strings.Trim(object.ETag, "\"")
+		}
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (target *s3Target) putObject(key string, srcPath string) error {
+	hash, err := sha256File(srcPath)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	headers := http.Header{"x-amz-content-sha256": {hash}}
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	body := &sizedReader{file, info.Size()}
+	response, err := target.do(http.MethodPut, key, nil, headers, body)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (target *s3Target) deleteObject(key string) error {
+	response, err := target.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// A file body with a known length, so requests are not sent chunked.
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+// Send a signed request for the given object key (or the bucket, if the key is empty),
+// using path-style urls, which are supported by S3-compatible services.
+// Returns an error if the response status is not successful.
+func (target *s3Target) do(method string, key string, query url.Values, headers http.Header, body *sizedReader) (*http.Response, error) {
+	requestUrl := *target.endpoint
+	requestUrl.Path = "/" + target.bucket
+	requestUrl.RawPath = "/" + uriEncode(target.bucket, false)
+	if key != "" {
+		requestUrl.Path += "/" + key
+		requestUrl.RawPath += "/" + uriEncode(key, false)
+	}
+	requestUrl.RawQuery = canonicalQuery(query)
+
+	var reader io.Reader
+	if body != nil {
+		reader = body
+	}
+	request, err := http.NewRequest(method, requestUrl.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = body.size
+	}
+	for name, values := range headers {
+		request.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	if request.Header.Get("x-amz-content-sha256") == "" {
+		request.Header.Set("x-amz-content-sha256", EMPTY_PAYLOAD_HASH)
+	}
+	signRequest(request, target.accessKey, target.secretKey, target.region, time.Now())
+
+	response, err := target.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("s3 %s %s failed with status %s: %s", method, requestUrl.Path, response.Status, strings.TrimSpace(string(message)))
+	}
+	return response, nil
+}
+
+// Add the AWS Signature Version 4 headers to the request, which must already include
+// the x-amz-content-sha256 header. The host, x-amz-* and Content-Type headers are signed.
+func signRequest(request *http.Request, accessKey string, secretKey string, region string, now time.Time) {
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+	request.Header.Set("x-amz-date", timestamp)
+
+	signed := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" {
+			signed[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + signed[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		request.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", timestamp, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// Return the query string with sorted keys and values encoded as required by the signature.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	params := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := slices.Clone(query[key])
+		slices.Sort(values)
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// Percent-encode every byte except the unreserved characters and, unless encodeSlash is set, slashes.
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, char := range []byte(value) {
+		isUnreserved := (char >= 'A' && char <= 'Z') || (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') ||
+			char == '-' || char == '_' || char == '.' || char == '~'
+		if isUnreserved || (char == '/' && !encodeSlash) {
+			encoded.WriteByte(char)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", char)
+		}
+	}
+	return encoded.String()
+}
+
+func sha256Hex(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
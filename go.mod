@@ -3,8 +3,10 @@ module github.com/facundoolano/jorge
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/alecthomas/chroma/v2 v2.17.0
 	github.com/alecthomas/kong v0.8.1
+	github.com/andybalholm/brotli v1.2.6
 	github.com/elliotchance/orderedmap/v2 v2.2.0
 	github.com/facundoolano/go-org v0.0.0-20240611152452-f50bf800e0ef
 	github.com/fsnotify/fsnotify v1.7.0
@@ -13,6 +15,7 @@ require (
 	github.com/yuin/goldmark v1.7.0
 	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
 	golang.org/x/net v0.23.0
+	golang.org/x/sys v0.18.0
 	golang.org/x/text v0.14.0
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -21,6 +24,5 @@ require (
 	github.com/dlclark/regexp2 v1.11.5 // indirect
 	github.com/osteele/tuesday v1.0.3 // indirect
 	github.com/tdewolff/parse/v2 v2.7.11 // indirect
-	golang.org/x/sys v0.18.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
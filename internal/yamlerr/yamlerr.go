@@ -0,0 +1,21 @@
+package yamlerr
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var lineRegex = regexp.MustCompile(`line (\d+): `)
+
+// Extract the line number embedded in the message of yaml errors, returning it and the rest of the message.
+func Split(err error) (int, string) {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	message = strings.TrimPrefix(message, "unmarshal errors:\n  ")
+	line := 0
+	if match := lineRegex.FindStringSubmatch(message); match != nil {
+		line, _ = strconv.Atoi(match[1])
+		message = strings.Replace(message, match[0], "", 1)
+	}
+	return line, message
+}
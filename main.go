@@ -10,7 +10,10 @@ var cli struct {
 	Build   commands.Build   `cmd:"" help:"Build a website project." aliases:"b"`
 	Post    commands.Post    `cmd:"" help:"Initialize a new post template file." aliases:"p"`
 	Serve   commands.Serve   `cmd:"" help:"Run a local server for the website." aliases:"s"`
+	Export  commands.Export  `cmd:"" help:"Export the website contents to other formats." aliases:"e"`
 	Meta    commands.Meta    `cmd:"" help:"Get the JSON results from evaluating a liquid template expression within the site context." aliases:"m"`
+	Config  commands.Config  `cmd:"" help:"Print the resolved site configuration." aliases:"c"`
+	Deploy  commands.Deploy  `cmd:"" help:"Build the website and publish it to a deploy target." aliases:"d"`
 	Version kong.VersionFlag `short:"v"`
 }
 
@@ -0,0 +1,384 @@
+package markup
+
+// Implements a renderer for a subset of the AsciiDoc syntax (https://docs.asciidoctor.org/asciidoc/latest/),
+// enough to render typical blog posts and documentation pages written for Asciidoctor-based sites:
+// section titles, paragraphs, nested lists, source and literal blocks, admonitions, quotes,
+// links, images and basic inline formatting. Unsupported syntax is rendered as plain text.
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var ADMONITION_TYPES = []string{"NOTE", "TIP", "IMPORTANT", "WARNING", "CAUTION"}
+
+var (
+	adocHeadingRegex     = regexp.MustCompile(`^(={1,6})\s+(.+)$`)
+	adocListItemRegex    = regexp.MustCompile(`^(\*+|-|\.+)\s+(.*)$`)
+	adocAttrEntryRegex   = regexp.MustCompile(`^:[\w-]+!?:`)
+	adocBlockTitleRegex  = regexp.MustCompile(`^\.([^.\s].*)$`)
+	adocBlockImageRegex  = regexp.MustCompile(`^image::([^\[\s]+)\[(.*)\]$`)
+	adocAdmonitionRegex  = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+(.*)$`)
+	adocInlineImageRegex = regexp.MustCompile(`image:([^\[\s:][^\[\s]*)\[([^\]]*)\]`)
+	adocLinkMacroRegex   = regexp.MustCompile(`link:([^\[\s]+)\[([^\]]*)\]`)
+	adocUrlMacroRegex    = regexp.MustCompile(`(^|[^"=\w])((?:https?|mailto)://[^\s\[<]+)\[([^\]]*)\]`)
+	adocBareUrlRegex     = regexp.MustCompile(`(^|[\s(])(https?://[^\s\[<)]+)`)
+	adocStrongRegex      = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*[^*\s])?)\*($|[^\w*])`)
+	adocEmphasisRegex    = regexp.MustCompile(`(^|[^\w_])_([^_\s](?:[^_]*[^_\s])?)_($|[^\w_])`)
+	adocNonWordRegex     = regexp.MustCompile(`[^\w]+`)
+)
+
+type adocParser struct {
+	lines   []string
+	pos     int
+	hlTheme string
+	out     strings.Builder
+
+	// block attributes (e.g. [source,go]) and title (.Title) preceding the current block
+	attrs []string
+	title string
+}
+
+func renderAsciiDoc(content []byte, srcPath string, hlTheme string) ([]byte, error) {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	parser := adocParser{lines: lines, hlTheme: hlTheme}
+	parser.parseBlocks()
+	return []byte(parser.out.String()), nil
+}
+
+// Consume the parser lines, writing the html for each of the blocks found.
+func (p *adocParser) parseBlocks() {
+	for p.pos < len(p.lines) {
+		line := strings.TrimRight(p.lines[p.pos], " \t")
+
+		switch {
+		case line == "":
+			p.pos++
+		case line == "////":
+			// comment block
+			p.delimitedLines(line)
+		case strings.HasPrefix(line, "//"):
+			p.pos++
+		case adocAttrEntryRegex.MatchString(line):
+			// document attributes aren't supported, skip them
+			p.pos++
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			p.attrs = strings.Split(line[1:len(line)-1], ",")
+			p.pos++
+			// keep the block metadata for the next block
+			continue
+		case adocBlockTitleRegex.MatchString(line):
+			p.title = adocBlockTitleRegex.FindStringSubmatch(line)[1]
+			p.pos++
+			continue
+		case adocHeadingRegex.MatchString(line):
+			match := adocHeadingRegex.FindStringSubmatch(line)
+			level := len(match[1])
+			fmt.Fprintf(&p.out, "<h%d id=\"%s\">%s</h%d>\n", level, adocSlug(match[2]), p.inline(match[2]), level)
+			p.pos++
+		case line == "----" || line == "```":
+			p.writeSourceBlock(p.delimitedLines(line))
+		case line == "....":
+			p.writeBlockTitle()
+			fmt.Fprintf(&p.out, "<pre>%s</pre>\n", html.EscapeString(strings.Join(p.delimitedLines(line), "\n")))
+		case line == "====":
+			p.writeExampleBlock(p.delimitedLines(line))
+		case line == "____":
+			p.writeNested("<blockquote>\n", p.delimitedLines(line), "</blockquote>\n")
+		case line == "'''":
+			p.out.WriteString("<hr>\n")
+			p.pos++
+		case adocBlockImageRegex.MatchString(line):
+			match := adocBlockImageRegex.FindStringSubmatch(line)
+			p.out.WriteString("<figure>\n")
+			fmt.Fprintf(&p.out, "<img src=\"%s\" alt=\"%s\">\n", html.EscapeString(match[1]), html.EscapeString(adocImageAlt(match[1], match[2])))
+			if p.title != "" {
+				fmt.Fprintf(&p.out, "<figcaption>%s</figcaption>\n", p.inline(p.title))
+			}
+			p.out.WriteString("</figure>\n")
+			p.pos++
+		case adocListItemRegex.MatchString(line):
+			depth, ordered, _ := adocListItem(line)
+			p.writeList(depth, ordered)
+		case adocAdmonitionRegex.MatchString(line):
+			match := adocAdmonitionRegex.FindStringSubmatch(line)
+			p.lines[p.pos] = match[2]
+			p.writeAdmonitionStart(match[1])
+			p.writeParagraph()
+			p.out.WriteString("</div>\n")
+		default:
+			if slices.Contains(ADMONITION_TYPES, p.blockStyle()) {
+				p.writeAdmonitionStart(p.blockStyle())
+				p.writeParagraph()
+				p.out.WriteString("</div>\n")
+			} else {
+				p.writeParagraph()
+			}
+		}
+
+		// block metadata only applies to the block immediately after it
+		p.attrs = nil
+		p.title = ""
+	}
+}
+
+// Return the first positional block attribute, e.g. source for [source,go]
+func (p *adocParser) blockStyle() string {
+	if len(p.attrs) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(p.attrs[0])
+}
+
+// Assuming the current line is a block delimiter, return the lines up to the matching closing delimiter,
+// leaving the parser positioned right after it.
+func (p *adocParser) delimitedLines(delimiter string) []string {
+	start := p.pos + 1
+	for p.pos = start; p.pos < len(p.lines); p.pos++ {
+		if strings.TrimRight(p.lines[p.pos], " \t") == delimiter {
+			p.pos++
+			return p.lines[start : p.pos-1]
+		}
+	}
+	// unclosed blocks extend to the end of the document
+	return p.lines[start:]
+}
+
+func (p *adocParser) writeBlockTitle() {
+	if p.title != "" {
+		fmt.Fprintf(&p.out, "<div class=\"title\">%s</div>\n", p.inline(p.title))
+	}
+}
+
+func (p *adocParser) writeSourceBlock(lines []string) {
+	p.writeBlockTitle()
+	source := strings.Join(lines, "\n")
+	lang := ""
+	if len(p.attrs) > 1 && p.blockStyle() == "source" {
+		lang = strings.TrimSpace(p.attrs[1])
+	}
+	if p.hlTheme != NO_SYNTAX_HIGHLIGHTING {
+		p.out.WriteString(highlightCodeBlock(p.hlTheme)(source, lang, false, nil))
+		p.out.WriteString("\n")
+		return
+	}
+	if lang != "" {
+		fmt.Fprintf(&p.out, "<pre><code class=\"language-%s\">%s</code></pre>\n", html.EscapeString(lang), html.EscapeString(source))
+	} else {
+		fmt.Fprintf(&p.out, "<pre><code>%s</code></pre>\n", html.EscapeString(source))
+	}
+}
+
+func (p *adocParser) writeExampleBlock(lines []string) {
+	style := p.blockStyle()
+	if slices.Contains(ADMONITION_TYPES, style) {
+		p.writeAdmonitionStart(style)
+		p.writeNested("", lines, "</div>\n")
+		return
+	}
+	p.writeBlockTitle()
+	p.writeNested("<div class=\"example\">\n", lines, "</div>\n")
+}
+
+func (p *adocParser) writeAdmonitionStart(kind string) {
+	label := kind[:1] + strings.ToLower(kind[1:])
+	fmt.Fprintf(&p.out, "<div class=\"admonition %s\">\n<p class=\"admonition-title\">%s</p>\n", strings.ToLower(kind), label)
+}
+
+// Render the given lines as a sequence of blocks, wrapped in the given opening and closing html.
+func (p *adocParser) writeNested(open string, lines []string, close string) {
+	nested := adocParser{lines: slices.Clone(lines), hlTheme: p.hlTheme}
+	nested.parseBlocks()
+	p.out.WriteString(open)
+	p.out.WriteString(nested.out.String())
+	p.out.WriteString(close)
+}
+
+// Write the lines starting at the current position until the next blank line
+// or block start as a paragraph.
+func (p *adocParser) writeParagraph() {
+	var lines []string
+	for p.pos < len(p.lines) {
+		line := strings.TrimSpace(p.lines[p.pos])
+		if line == "" || (len(lines) > 0 && p.isBlockStart(line)) {
+			break
+		}
+		lines = append(lines, p.inlineWithBreaks(line))
+		p.pos++
+	}
+	p.writeBlockTitle()
+	fmt.Fprintf(&p.out, "<p>%s</p>\n", strings.Join(lines, "\n"))
+}
+
+func (p *adocParser) isBlockStart(line string) bool {
+	switch line {
+	case "----", "```", "....", "====", "____", "////", "'''":
+		return true
+	}
+	return adocListItemRegex.MatchString(line) || adocHeadingRegex.MatchString(line) || adocBlockImageRegex.MatchString(line)
+}
+
+// Write a list at the given nesting depth, recursively handling nested lists
+// and continuation lines of items.
+func (p *adocParser) writeList(depth int, ordered bool) {
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	fmt.Fprintf(&p.out, "<%s>\n", tag)
+
+	for p.pos < len(p.lines) {
+		line := strings.TrimSpace(p.lines[p.pos])
+		if line == "" {
+			// blank lines between items don't end the list
+			next := p.nextNonBlank()
+			if next == -1 || !adocListItemRegex.MatchString(strings.TrimSpace(p.lines[next])) {
+				break
+			}
+			p.pos = next
+			continue
+		}
+
+		itemDepth, itemOrdered, text := adocListItem(line)
+		if itemDepth == 0 || itemDepth < depth || (itemDepth == depth && itemOrdered != ordered) {
+			break
+		}
+
+		p.out.WriteString("<li>")
+		p.out.WriteString(p.inlineWithBreaks(text))
+		p.pos++
+
+		// append continuation lines to the item text
+		for p.pos < len(p.lines) {
+			next := strings.TrimSpace(p.lines[p.pos])
+			if next == "" || p.isBlockStart(next) {
+				break
+			}
+			p.out.WriteString("\n" + p.inlineWithBreaks(next))
+			p.pos++
+		}
+
+		// nested lists are rendered inside the item, including consecutive lists of different types
+		nested := false
+		for next := p.nextNonBlank(); next != -1; next = p.nextNonBlank() {
+			nextDepth, nextOrdered, _ := adocListItem(strings.TrimSpace(p.lines[next]))
+			if nextDepth <= depth {
+				break
+			}
+			if !nested {
+				p.out.WriteString("\n")
+				nested = true
+			}
+			p.pos = next
+			p.writeList(nextDepth, nextOrdered)
+		}
+		p.out.WriteString("</li>\n")
+	}
+
+	fmt.Fprintf(&p.out, "</%s>\n", tag)
+}
+
+func (p *adocParser) nextNonBlank() int {
+	for i := p.pos; i < len(p.lines); i++ {
+		if strings.TrimSpace(p.lines[i]) != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// Parse a list item line, returning its nesting depth (0 if it's not a list item),
+// whether it's an ordered list and the item text.
+func adocListItem(line string) (int, bool, string) {
+	match := adocListItemRegex.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false, ""
+	}
+	marker := match[1]
+	if marker == "-" {
+		return 1, false, match[2]
+	}
+	return len(marker), marker[0] == '.', match[2]
+}
+
+// Convert a line of text to html, handling a trailing " +" as a hard line break.
+func (p *adocParser) inlineWithBreaks(line string) string {
+	if strings.HasSuffix(line, " +") {
+		return p.inline(strings.TrimSuffix(line, " +")) + "<br>"
+	}
+	return p.inline(line)
+}
+
+// Escape the given text and replace the supported inline markup with html:
+// `monospace`, *strong*, _emphasis_, links and images.
+func (p *adocParser) inline(text string) string {
+	// split on backticks so the contents of monospace spans are left untouched
+	parts := strings.Split(text, "`")
+	var result strings.Builder
+	for i, part := range parts {
+		isCode := i%2 == 1 && i < len(parts)-1
+		if isCode {
+			result.WriteString("<code>" + html.EscapeString(part) + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			// unbalanced backtick, keep it as found
+			result.WriteString("`")
+		}
+
+		part = html.EscapeString(part)
+		part = adocInlineImageRegex.ReplaceAllStringFunc(part, func(match string) string {
+			groups := adocInlineImageRegex.FindStringSubmatch(match)
+			return fmt.Sprintf(`<img src="%s" alt="%s">`, groups[1], adocImageAlt(groups[1], groups[2]))
+		})
+		part = adocLinkMacroRegex.ReplaceAllStringFunc(part, func(match string) string {
+			groups := adocLinkMacroRegex.FindStringSubmatch(match)
+			return adocLink(groups[1], groups[2])
+		})
+		part = adocUrlMacroRegex.ReplaceAllStringFunc(part, func(match string) string {
+			groups := adocUrlMacroRegex.FindStringSubmatch(match)
+			return groups[1] + adocLink(groups[2], groups[3])
+		})
+		part = adocBareUrlRegex.ReplaceAllString(part, `$1<a href="$2">$2</a>`)
+		part = replaceAllRepeated(adocStrongRegex, part, "$1<strong>$2</strong>$3")
+		part = replaceAllRepeated(adocEmphasisRegex, part, "$1<em>$2</em>$3")
+		result.WriteString(part)
+	}
+	return result.String()
+}
+
+// Apply the replacement until there are no more matches, since the boundary characters
+// consumed by a match can prevent an adjacent one from matching in a single pass.
+func replaceAllRepeated(regex *regexp.Regexp, text string, replacement string) string {
+	for regex.MatchString(text) {
+		text = regex.ReplaceAllString(text, replacement)
+	}
+	return text
+}
+
+func adocLink(target string, text string) string {
+	if text == "" {
+		text = target
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, target, text)
+}
+
+// Return the image alt text from its macro attributes, defaulting to the file name.
+func adocImageAlt(target string, attrs string) string {
+	alt := strings.TrimSpace(strings.Split(attrs, ",")[0])
+	if alt == "" {
+		alt = target[strings.LastIndex(target, "/")+1:]
+		if dot := strings.LastIndex(alt, "."); dot > 0 {
+			alt = alt[:dot]
+		}
+	}
+	return alt
+}
+
+func adocSlug(title string) string {
+	slug := adocNonWordRegex.ReplaceAllString(strings.ToLower(title), "-")
+	return strings.Trim(slug, "-")
+}
@@ -0,0 +1,50 @@
+package markup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"slices"
+
+	"github.com/andybalholm/brotli"
+)
+
+var COMPRESSIBLE_EXTENSIONS = []string{".css", ".html", ".js", ".json", ".svg", ".txt", ".xml"}
+
+// A content encoding used to write precompressed versions of the site files,
+// as siblings of the original file with the encoding extension.
+type Encoding struct {
+	// the name of the encoding as used in the Accept-Encoding and Content-Encoding headers
+	Name   string
+	Ext    string
+	writer func(io.Writer) io.WriteCloser
+}
+
+// The supported encodings, in order of preference.
+var ENCODINGS = []Encoding{
+	{Name: "br", Ext: ".br", writer: func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestCompression)
+	}},
+	{Name: "gzip", Ext: ".gz", writer: func(w io.Writer) io.WriteCloser {
+		writer, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return writer
+	}},
+}
+
+// Report whether files with the given extension are worth compressing.
+func IsCompressible(ext string) bool {
+	return slices.Contains(COMPRESSIBLE_EXTENSIONS, ext)
+}
+
+// Return the given content compressed with the encoding.
+func (encoding Encoding) Compress(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := encoding.writer(&buf)
+	if _, err := writer.Write(content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
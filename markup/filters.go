@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/url"
+	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -24,7 +25,7 @@ import (
 // a lot of the filters and tags available at jekyll aren't default liquid manually adding them here
 // copied from https://github.com/osteele/gojekyll/blob/f1794a874890bfb601cae767a0cce15d672e9058/filters/filters.go
 // MIT License: https://github.com/osteele/gojekyll/blob/f1794a874890bfb601cae767a0cce15d672e9058/LICENSE
-func loadJekyllFilters(e *liquid.Engine, siteUrl string, includesDir string) {
+func loadJekyllFilters(e *liquid.Engine, siteUrl string, includesDir string, onWarning WarningHandler) {
 	e.RegisterFilter("filter", filter)
 	e.RegisterFilter("group_by", groupByFilter)
 	e.RegisterFilter("group_by_exp", groupByExpFilter)
@@ -92,7 +93,7 @@ func loadJekyllFilters(e *liquid.Engine, siteUrl string, includesDir string) {
 	})
 
 	e.RegisterTag("include", func(rc render.Context) (string, error) {
-		return includeFromDir(includesDir, rc)
+		return includeFromDir(includesDir, rc, onWarning)
 	})
 }
 
@@ -221,7 +222,7 @@ func whereFilter(array []map[string]interface{}, key string, value interface{})
 	return result
 }
 
-func includeFromDir(dir string, rc render.Context) (string, error) {
+func includeFromDir(dir string, rc render.Context, onWarning WarningHandler) (string, error) {
 	argsline, err := rc.ExpandTagArg()
 	if err != nil {
 		return "", err
@@ -235,5 +236,12 @@ func includeFromDir(dir string, rc render.Context) (string, error) {
 	}
 
 	filename := filepath.Join(dir, args[0])
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		// report missing includes as warnings, if the caller is collecting them
+		if onWarning != nil {
+			onWarning(rc.Bindings(), fmt.Sprintf("include '%s' not found", args[0]))
+			return "", nil
+		}
+	}
 	return rc.RenderFile(filename, map[string]interface{}{})
 }
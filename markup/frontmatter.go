@@ -0,0 +1,266 @@
+package markup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/facundoolano/jorge/internal/yamlerr"
+	"gopkg.in/yaml.v3"
+)
+
+const FM_SEPARATOR = "---"
+const TOML_FM_SEPARATOR = "+++"
+
+// The front matter keys that need a boolean value, since they decide how the template is built.
+var BOOL_KEYS = []string{"draft", "published"}
+
+// The layouts accepted for front matter dates given as strings (e.g. from JSON front matter),
+// so they are handled the same as the ones parsed natively by YAML and TOML.
+var DATE_LAYOUTS = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// An error found when parsing the front matter of a template,
+// pointing to its location in the source file.
+type FrontMatterError struct {
+	Path   string
+	Line   int
+	Format string
+	Err    error
+}
+
+func (err *FrontMatterError) Error() string {
+	return fmt.Sprintf("invalid %s front matter: File '%s', line %d: %s", err.Format, err.Path, err.Line, err.Err)
+}
+
+func (err *FrontMatterError) Unwrap() error {
+	return err.Err
+}
+
+// Return true if the given first line of a file starts a front matter block.
+// JSON front matter (a line that is just `{` or starts with `{"`) is only considered for files with
+// a registered markup renderer, to avoid treating static JSON files as templates. Other lines starting
+// with a brace, like liquid tags (`{% raw %}`), are left as content.
+func isFrontMatterStart(path string, line string) bool {
+	line = strings.TrimSpace(line)
+	if line == FM_SEPARATOR || line == TOML_FM_SEPARATOR {
+		return true
+	}
+	_, isMarkup := renderers[filepath.Ext(path)]
+	return isMarkup && (line == "{" || strings.HasPrefix(line, `{"`))
+}
+
+// Split the given file contents into their front matter metadata and the rest of the template content.
+// The front matter can be either `---` delimited YAML, `+++` delimited TOML or a JSON object.
+// Also return the line number where the template content starts.
+func parseFrontMatter(path string, data []byte) (map[string]interface{}, []byte, int, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	lines := strings.Split(string(data), "\n")
+	firstLine := strings.TrimSpace(lines[0])
+
+	var metadata map[string]interface{}
+	var content string
+	var contentLine int
+	var err *FrontMatterError
+
+	// the front matter lines and the file line number where they start, used to report errors
+	var fmLines []string
+	var fmStart int
+
+	format := "yaml"
+	switch firstLine {
+	case FM_SEPARATOR, TOML_FM_SEPARATOR:
+		if firstLine == TOML_FM_SEPARATOR {
+			format = "toml"
+		}
+
+		closing := -1
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == firstLine {
+				closing = i
+				break
+			}
+		}
+		if closing == -1 {
+			return nil, nil, 0, &FrontMatterError{path, 1, format, errors.New("front matter not closed")}
+		}
+
+		fmLines = lines[1:closing]
+		fmStart = 2
+		content = strings.Join(lines[closing+1:], "\n")
+		contentLine = closing + 2
+		if format == "yaml" {
+			metadata, err = decodeYaml(path, strings.Join(fmLines, "\n"))
+		} else {
+			metadata, err = decodeToml(path, strings.Join(fmLines, "\n")+"\n")
+		}
+	default:
+		format = "json"
+		var end int
+		metadata, end, err = decodeJson(path, data)
+		if err == nil {
+			// the content starts in the line after the closing brace
+			if newline := bytes.IndexByte(data[end:], '\n'); newline != -1 {
+				end += newline + 1
+			} else {
+				end = len(data)
+			}
+			fmLines = lines[:bytes.Count(data[:end], []byte("\n"))]
+			fmStart = 1
+			content = string(data[end:])
+			contentLine = len(fmLines) + 1
+		}
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	normalizeValues(metadata)
+	// report value errors at the line where their key is defined
+	keyLine := func(key string) int {
+		keyRegex := regexp.MustCompile(`^\s*"?` + regexp.QuoteMeta(key) + `"?\s*[:=]`)
+		for i, fmLine := range fmLines {
+			if keyRegex.MatchString(fmLine) {
+				return fmStart + i
+			}
+		}
+		return fmStart
+	}
+	if key, err := NormalizeMetadata(metadata); err != nil {
+		return nil, nil, 0, &FrontMatterError{path, keyLine(key), format, err}
+	}
+
+	content = strings.TrimSuffix(content, "\n")
+	return metadata, []byte(content), contentLine, nil
+}
+
+func decodeYaml(path string, content string) (map[string]interface{}, *FrontMatterError) {
+	metadata := make(map[string]interface{})
+	if len(strings.TrimSpace(content)) == 0 {
+		return metadata, nil
+	}
+	if err := yaml.Unmarshal([]byte(content), &metadata); err != nil {
+		// yaml errors embed the line number in the message, extract it to make it relative to the file
+		line, message := yamlerr.Split(err)
+		// add one line to account for the opening separator
+		return nil, &FrontMatterError{path, line + 1, "yaml", errors.New(message)}
+	}
+	return metadata, nil
+}
+
+var tomlLineRegex = regexp.MustCompile(`^toml: line \d+( \(last key "[^"]*"\))?: `)
+
+func decodeToml(path string, content string) (map[string]interface{}, *FrontMatterError) {
+	metadata := make(map[string]interface{})
+	if _, err := toml.Decode(content, &metadata); err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			message := tomlLineRegex.ReplaceAllString(parseErr.Error(), "")
+			return nil, &FrontMatterError{path, parseErr.Position.Line + 1, "toml", errors.New(message)}
+		}
+		return nil, &FrontMatterError{path, 1, "toml", err}
+	}
+	return metadata, nil
+}
+
+// Decode the JSON object at the start of data, returning the offset where it ends.
+func decodeJson(path string, data []byte) (map[string]interface{}, int, *FrontMatterError) {
+	metadata := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&metadata); err != nil {
+		offset := decoder.InputOffset()
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		} else if errors.As(err, &typeErr) {
+			offset = typeErr.Offset
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = errors.New("front matter not closed")
+		}
+		line := bytes.Count(data[:min(int(offset), len(data))], []byte("\n")) + 1
+		return nil, 0, &FrontMatterError{path, line, "json", err}
+	}
+	return metadata, int(decoder.InputOffset()), nil
+}
+
+// Convert the numeric types produced by the different decoders to int or float64,
+// so templates get the same values regardless of the front matter format.
+func normalizeValues(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = normalizeValues(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeValues(item)
+		}
+	case []map[string]interface{}:
+		// toml arrays of tables
+		for _, item := range value {
+			normalizeValues(item)
+		}
+	case json.Number:
+		if integer, err := value.Int64(); err == nil {
+			return int(integer)
+		}
+		float, _ := value.Float64()
+		return float
+	case int64:
+		return int(value)
+	}
+	return value
+}
+
+// Ensure the front matter date, if present, is a time.Time. YAML and TOML dates are already
+// parsed by their decoders, but JSON and quoted values need to be parsed manually.
+// Check the types of the metadata values the site depends on, parsing the date if it's a string.
+// On error, the offending key is returned too.
+func NormalizeMetadata(metadata map[string]interface{}) (string, error) {
+	if err := normalizeDate(metadata); err != nil {
+		return "date", err
+	}
+	for _, key := range BOOL_KEYS {
+		if value, ok := metadata[key]; ok {
+			if _, isBool := value.(bool); !isBool {
+				return key, fmt.Errorf("'%s' expected true or false, got %s", key, strconv.Quote(fmt.Sprint(value)))
+			}
+		}
+	}
+	return "", nil
+}
+
+func normalizeDate(metadata map[string]interface{}) error {
+	date, ok := metadata["date"]
+	if !ok {
+		return nil
+	}
+	switch date := date.(type) {
+	case time.Time:
+		return nil
+	case string:
+		for _, layout := range DATE_LAYOUTS {
+			if parsed, err := time.Parse(layout, date); err == nil {
+				metadata["date"] = parsed
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("can't parse '%v' as a date", date)
+}
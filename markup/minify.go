@@ -29,16 +29,19 @@ func LoadMinifier(exclusions []string) Minifier {
 }
 
 func (m *Minifier) Minify(path string, contentReader io.Reader) io.Reader {
+	if !m.IsMinifiable(path) {
+		return contentReader
+	}
+	return m.minifier.Reader(filepath.Ext(path), contentReader)
+}
 
+// Report whether the file at the given path would be minified, based on its extension
+// and the configured exclusions.
+func (m *Minifier) IsMinifiable(path string) bool {
 	for _, exclusion := range m.exclusions {
 		if matched, _ := filepath.Match(exclusion, path); matched {
-			return contentReader
+			return false
 		}
 	}
-
-	extension := filepath.Ext(path)
-	if !slices.Contains(SUPPORTED_MINIFIERS, extension) {
-		return contentReader
-	}
-	return m.minifier.Reader(extension, contentReader)
+	return slices.Contains(SUPPORTED_MINIFIERS, filepath.Ext(path))
 }
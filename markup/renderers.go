@@ -0,0 +1,104 @@
+package markup
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/facundoolano/go-org/org"
+	"github.com/yuin/goldmark"
+	gm_highlight "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+)
+
+// A Renderer converts the result of rendering a liquid template written in some
+// markup language (e.g. org-mode or markdown) to HTML.
+// The `srcPath` is the location of the template source file and `hlTheme` the chroma style
+// to use for syntax highlighting of code blocks (or NO_SYNTAX_HIGHLIGHTING).
+type Renderer func(content []byte, srcPath string, hlTheme string) ([]byte, error)
+
+var renderers = make(map[string]Renderer)
+
+func init() {
+	RegisterRenderer(".org", renderOrg)
+	RegisterRenderer(".md", renderMarkdown)
+	RegisterRenderer(".adoc", renderAsciiDoc)
+	RegisterRenderer(".asciidoc", renderAsciiDoc)
+}
+
+// Register a renderer for template source files with the given extension (including the dot, e.g. ".org").
+// Templates with a registered extension are converted to HTML after their liquid rendering
+// and their target file gets an .html extension.
+func RegisterRenderer(extension string, renderer Renderer) {
+	renderers[extension] = renderer
+}
+
+func renderOrg(content []byte, srcPath string, hlTheme string) ([]byte, error) {
+	doc := org.New().Parse(bytes.NewReader(content), srcPath)
+	htmlWriter := org.NewHTMLWriter()
+
+	// make * -> h1, ** -> h2, etc
+	htmlWriter.TopLevelHLevel = 1
+	// handle relative paths in links
+	htmlWriter.PrettyRelativeLinks = true
+	if hlTheme != NO_SYNTAX_HIGHLIGHTING {
+		htmlWriter.HighlightCodeBlock = highlightCodeBlock(hlTheme)
+	}
+
+	contentStr, err := doc.Write(htmlWriter)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(contentStr), nil
+}
+
+func renderMarkdown(content []byte, srcPath string, hlTheme string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	options := make([]goldmark.Option, 0)
+	if hlTheme != NO_SYNTAX_HIGHLIGHTING {
+
+		options = append(options, goldmark.WithExtensions(
+			extension.GFM,
+			extension.Footnote,
+			gm_highlight.NewHighlighting(
+				gm_highlight.WithStyle(hlTheme),
+				gm_highlight.WithFormatOptions(html.TabWidth(CODE_TABWIDTH)),
+			)))
+	}
+	md := goldmark.New(options...)
+	if err := md.Convert(content, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func highlightCodeBlock(hlTheme string) func(source string, lang string, inline bool, params map[string]string) string {
+	// from https://github.com/niklasfasching/go-org/blob/a32df1461eb34a451b1e0dab71bd9b2558ea5dc4/blorg/util.go#L58
+	return func(source, lang string, inline bool, params map[string]string) string {
+		var w strings.Builder
+		l := lexers.Get(lang)
+		if l == nil {
+			l = lexers.Fallback
+		}
+		l = chroma.Coalesce(l)
+		it, _ := l.Tokenise(nil, source)
+		options := []html.Option{
+			html.TabWidth(CODE_TABWIDTH),
+		}
+		if params[":hl_lines"] != "" {
+			ranges := org.ParseRanges(params[":hl_lines"])
+			if ranges != nil {
+				options = append(options, html.HighlightLines(ranges))
+			}
+		}
+		_ = html.New(options...).Format(&w, styles.Get(hlTheme), it)
+		if inline {
+			return `<div class="highlight-inline">` + "\n" + w.String() + "\n" + `</div>`
+		}
+		return `<div class="highlight">` + "\n" + w.String() + "\n" + `</div>`
+	}
+}
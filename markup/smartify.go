@@ -17,8 +17,13 @@ import (
 
 var SKIP_TAGS = []string{"pre", "code", "kbd", "script", "math"}
 
+// Report whether files with the given extension are processed by Smartify.
+func IsSmartifiable(extension string) bool {
+	return extension == ".html"
+}
+
 func Smartify(extension string, contentReader io.Reader) (io.Reader, error) {
-	if extension != ".html" {
+	if !IsSmartifiable(extension) {
 		return contentReader, nil
 	}
 	node, err := html.Parse(contentReader)
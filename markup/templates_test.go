@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseTemplate(t *testing.T) {
@@ -18,7 +19,7 @@ tags: ["software", "web"]
 	file := newFile("test*.html", input)
 	defer os.Remove(file.Name())
 
-	templ, err := Parse(NewEngine("https://olano.dev", "includes"), file.Name())
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
 	assertEqual(t, err, nil)
 
 	assertEqual(t, templ.Metadata["title"], "my new post")
@@ -33,17 +34,40 @@ tags: ["software", "web"]
 
 func TestNonTemplate(t *testing.T) {
 	// not identified as front matter, leaving file as is
-	input := `+++
+	input := `***
 title: my new post
 subtitle: a blog post
-+++
+***
 <p>Hello World!</p>`
 
 	file := newFile("test*.html", input)
 	defer os.Remove(file.Name())
 
-	_, err := Parse(NewEngine("https://olano.dev", "includes"), file.Name())
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err, nil)
+	assert(t, templ == nil)
+
+	// json front matter is only considered for markup files, not for static json or html
+	input = `{
+  "title": "my new post"
+}`
+
+	file = newFile("test*.json", input)
+	defer os.Remove(file.Name())
+
+	templ, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
 	assertEqual(t, err, nil)
+	assert(t, templ == nil)
+
+	// neither are markup files starting with liquid tags or variables
+	for _, input := range []string{"{% raw %}\n{{ not a template }}\n{% endraw %}", "{{ page.title }}\n"} {
+		file = newFile("test*.md", input)
+		defer os.Remove(file.Name())
+
+		templ, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+		assertEqual(t, err, nil)
+		assert(t, templ == nil)
+	}
 
 	// not first thing in file, leaving as is
 	input = `#+OPTIONS: toc:nil num:nil
@@ -57,7 +81,7 @@ tags: ["software", "web"]
 	file = newFile("test*.html", input)
 	defer os.Remove(file.Name())
 
-	_, err = Parse(NewEngine("https://olano.dev", "includes"), file.Name())
+	_, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
 	assertEqual(t, err, nil)
 }
 
@@ -69,9 +93,9 @@ tags: ["software", "web"]
 `
 	file := newFile("test*.html", input)
 	defer os.Remove(file.Name())
-	_, err := Parse(NewEngine("https://olano.dev", "includes"), file.Name())
+	_, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
 
-	assertEqual(t, err.Error(), "front matter not closed")
+	assertEqual(t, err.Error(), "invalid yaml front matter: File '"+file.Name()+"', line 1: front matter not closed")
 
 	input = `---
 title
@@ -81,8 +105,113 @@ tags: ["software", "web"]
 
 	file = newFile("test*.html", input)
 	defer os.Remove(file.Name())
-	_, err = Parse(NewEngine("https://olano.dev", "includes"), file.Name())
+	_, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
 	assert(t, strings.Contains(err.Error(), "invalid yaml"))
+
+	input = `+++
+title = "my new post"
+subtitle = "a blog post
++++
+<p>Hello World!</p>`
+
+	file = newFile("test*.md", input)
+	defer os.Remove(file.Name())
+	_, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	fmErr := err.(*FrontMatterError)
+	assertEqual(t, fmErr.Format, "toml")
+	assertEqual(t, fmErr.Line, 3)
+
+	input = `{
+  "title": "my new post",
+  "tags": ["software", "web"],,
+}
+<p>Hello World!</p>`
+
+	file = newFile("test*.md", input)
+	defer os.Remove(file.Name())
+	_, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	fmErr = err.(*FrontMatterError)
+	assertEqual(t, fmErr.Format, "json")
+	assertEqual(t, fmErr.Line, 3)
+
+	input = `---
+title: my new post
+date: yesterday
+---
+<p>Hello World!</p>`
+
+	file = newFile("test*.md", input)
+	defer os.Remove(file.Name())
+	_, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err.Error(), "invalid yaml front matter: File '"+file.Name()+"', line 3: can't parse 'yesterday' as a date")
+
+	input = `---
+title: my new post
+published: no
+---
+<p>Hello World!</p>`
+
+	file = newFile("test*.md", input)
+	defer os.Remove(file.Name())
+	_, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err.Error(), "invalid yaml front matter: File '"+file.Name()+"', line 3: 'published' expected true or false, got \"no\"")
+
+	input = `{
+  "title": "my new post",
+  "draft": 1
+}
+<p>Hello World!</p>`
+
+	file = newFile("test*.md", input)
+	defer os.Remove(file.Name())
+	_, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err.Error(), "invalid json front matter: File '"+file.Name()+"', line 3: 'draft' expected true or false, got \"1\"")
+}
+
+func TestParseTomlAndJsonFrontMatter(t *testing.T) {
+	expectedDate := time.Date(2024, 1, 2, 10, 30, 0, 0, time.UTC)
+
+	input := `+++
+title = "my new post"
+tags = ["software", "web"]
+date = 2024-01-02T10:30:00Z
+weight = 3
++++
+# {{ page.title }}
+`
+	file := newFile("test*.md", input)
+	defer os.Remove(file.Name())
+
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err, nil)
+	assertEqual(t, templ.Metadata["title"], "my new post")
+	assertEqual(t, templ.Metadata["tags"].([]interface{})[1], "web")
+	assertEqual(t, templ.Metadata["weight"], 3)
+	assert(t, templ.Metadata["date"].(time.Time).Equal(expectedDate))
+	content, err := templ.Render()
+	assertEqual(t, err, nil)
+	assertEqual(t, string(content), "<h1>my new post</h1>\n")
+
+	input = `{
+  "title": "my new post",
+  "tags": ["software", "web"],
+  "date": "2024-01-02T10:30:00Z",
+  "weight": 3
+}
+# {{ page.title }}
+`
+	file = newFile("test*.md", input)
+	defer os.Remove(file.Name())
+
+	templ, err = Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err, nil)
+	assertEqual(t, templ.Metadata["title"], "my new post")
+	assertEqual(t, templ.Metadata["tags"].([]interface{})[1], "web")
+	assertEqual(t, templ.Metadata["weight"], 3)
+	assert(t, templ.Metadata["date"].(time.Time).Equal(expectedDate))
+	content, err = templ.Render()
+	assertEqual(t, err, nil)
+	assertEqual(t, string(content), "<h1>my new post</h1>\n")
 }
 
 func TestRenderLiquid(t *testing.T) {
@@ -100,7 +229,7 @@ tags: ["software", "web"]
 	file := newFile("test*.html", input)
 	defer os.Remove(file.Name())
 
-	templ, err := Parse(NewEngine("https://olano.dev", "includes"), file.Name())
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
 	assertEqual(t, err, nil)
 	content, err := templ.Render()
 	assertEqual(t, err, nil)
@@ -113,6 +242,37 @@ tags: ["software", "web"]
 	assertEqual(t, string(content), expected)
 }
 
+func TestRenderMissingInclude(t *testing.T) {
+	input := `---
+title: my new post
+---
+<h1>{% include missing.html %}{{ page.title }}</h1>`
+
+	file := newFile("test*.html", input)
+	defer os.Remove(file.Name())
+
+	// without a warning handler, missing includes fail the render
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err, nil)
+	_, err = templ.RenderWith(map[string]interface{}{"page": templ.Metadata}, NO_SYNTAX_HIGHLIGHTING)
+	assert(t, err != nil)
+
+	var warnings []string
+	var warningPage interface{}
+	onWarning := func(ctx map[string]interface{}, message string) {
+		warningPage = ctx["page"].(map[string]interface{})["title"]
+		warnings = append(warnings, message)
+	}
+	templ, err = Parse(NewEngine("https://olano.dev", "includes", onWarning), file.Name())
+	assertEqual(t, err, nil)
+	content, err := templ.RenderWith(map[string]interface{}{"page": templ.Metadata}, NO_SYNTAX_HIGHLIGHTING)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(content), "<h1>my new post</h1>")
+	assertEqual(t, len(warnings), 1)
+	assertEqual(t, warnings[0], "include 'missing.html' not found")
+	assertEqual(t, warningPage, "my new post")
+}
+
 func TestRenderOrg(t *testing.T) {
 	input := `---
 title: my new post
@@ -129,7 +289,7 @@ tags: ["software", "web"]
 	file := newFile("test*.org", input)
 	defer os.Remove(file.Name())
 
-	templ, err := Parse(NewEngine("https://olano.dev", "includes"), file.Name())
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
 	assertEqual(t, err, nil)
 
 	content, err := templ.Render()
@@ -163,7 +323,7 @@ tags: ["software", "web"]
 	file := newFile("test*.md", input)
 	defer os.Remove(file.Name())
 
-	templ, err := Parse(NewEngine("https://olano.dev", "includes"), file.Name())
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
 	assertEqual(t, err, nil)
 
 	content, err := templ.Render()
@@ -178,6 +338,123 @@ tags: ["software", "web"]
 	assertEqual(t, string(content), expected)
 }
 
+func TestRenderAsciiDoc(t *testing.T) {
+	input := `---
+title: my new post
+---
+= My title
+:toc:
+
+== my Subtitle
+A paragraph with *strong*, _emphasized_ and ` + "`mono_spaced`" + ` text,
+a https://example.com[link] and an image:icon.png[icon].
+
+* list 1
+** nested
+* list 2
+
+. first
+. second
+
+NOTE: an admonition paragraph
+
+[source,go]
+----
+fmt.Println("<hi>")
+----
+
+image::img/photo.jpg[]
+
+[WARNING]
+====
+careful!
+====
+`
+
+	file := newFile("test*.adoc", input)
+	defer os.Remove(file.Name())
+
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err, nil)
+	assertEqual(t, templ.TargetExt(), ".html")
+
+	content, err := templ.Render()
+	assertEqual(t, err, nil)
+	expected := `<h1 id="my-title">My title</h1>
+<h2 id="my-subtitle">my Subtitle</h2>
+<p>A paragraph with <strong>strong</strong>, <em>emphasized</em> and <code>mono_spaced</code> text,
+a <a href="https://example.com">link</a> and an <img src="icon.png" alt="icon">.</p>
+<ul>
+<li>list 1
+<ul>
+<li>nested</li>
+</ul>
+</li>
+<li>list 2</li>
+</ul>
+<ol>
+<li>first</li>
+<li>second</li>
+</ol>
+<div class="admonition note">
+<p class="admonition-title">Note</p>
+<p>an admonition paragraph</p>
+</div>
+<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>
+<figure>
+<img src="img/photo.jpg" alt="photo">
+</figure>
+<div class="admonition warning">
+<p class="admonition-title">Warning</p>
+<p>careful!</p>
+</div>
+`
+	assertEqual(t, string(content), expected)
+}
+
+func TestRenderAsciiDocNestedLists(t *testing.T) {
+	input := `---
+title: lists
+---
+* a
+. b
+** c
+.. d
+
+** e
+. f
+`
+
+	file := newFile("test*.adoc", input)
+	defer os.Remove(file.Name())
+
+	templ, err := Parse(NewEngine("https://olano.dev", "includes", nil), file.Name())
+	assertEqual(t, err, nil)
+	content, err := templ.Render()
+	assertEqual(t, err, nil)
+
+	// sub-lists of different types stay inside the parent item
+	expected := `<ul>
+<li>a</li>
+</ul>
+<ol>
+<li>b
+<ul>
+<li>c</li>
+</ul>
+<ol>
+<li>d</li>
+</ol>
+<ul>
+<li>e</li>
+</ul>
+</li>
+<li>f</li>
+</ol>
+`
+	assertEqual(t, string(content), expected)
+}
+
 // ------ HELPERS --------
 
 func newFile(path string, contents string) *os.File {
@@ -0,0 +1,83 @@
+package site
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Read and decode the data file at the given path according to its extension:
+// .yml, .yaml, .json and .toml files are decoded as is,
+// while .csv and .tsv files are decoded as a list of maps keyed by the column headers.
+// Files without an extension are decoded as yaml.
+// The returned flag is false if the file extension is not supported.
+func loadDataFile(path string) (interface{}, bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, true, err
+	}
+
+	var data interface{}
+	var format string
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml", "":
+		format = "yaml"
+		err = yaml.Unmarshal(content, &data)
+	case ".json":
+		format = "json"
+		err = json.Unmarshal(content, &data)
+	case ".toml":
+		format = "toml"
+		tomlData := make(map[string]interface{})
+		_, err = toml.Decode(string(content), &tomlData)
+		data = tomlData
+	case ".csv":
+		format = "csv"
+		data, err = decodeCSV(content, ',')
+	case ".tsv":
+		format = "tsv"
+		data, err = decodeCSV(content, '\t')
+	default:
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid %s format: File '%s', %w", format, path, err)
+	}
+	return data, true, nil
+}
+
+// Decode the given delimited values, using the first row as the keys for each of the rest.
+func decodeCSV(content []byte, separator rune) ([]map[string]interface{}, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = separator
+	if separator == '\t' {
+		// tsv files don't usually quote their fields
+		reader.LazyQuotes = true
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]interface{}, 0)
+	if len(records) == 0 {
+		return rows, nil
+	}
+	headers := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(headers))
+		for i, header := range headers {
+			row[header] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,335 @@
+package site
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/facundoolano/jorge/config"
+	"golang.org/x/net/html"
+)
+
+// The number of unchanged lines shown around each change in unified diffs.
+const DIFF_CONTEXT_LINES = 3
+
+// The differences between the files of a site rendered in memory and the ones currently
+// at the target directory. Paths are slash-separated and relative to the target directory.
+type Changes struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+
+	targetDir string
+	files     map[string][]byte
+}
+
+// Render the site in memory, without modifying the target directory,
+// and compare the result with the current target contents.
+func DryRun(config config.Config) (*BuildReport, *Changes, error) {
+	site, err := load(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	memory := newMemoryOutput()
+	err = site.buildInMemory(memory)
+	site.report.finish()
+	site.report.printIssues()
+	if err != nil {
+		return site.report, nil, err
+	}
+
+	changes, err := compareOutput(memory.files, config.TargetDir)
+	return site.report, changes, err
+}
+
+// Compare the given rendered files with the contents of the target directory.
+func compareOutput(files map[string][]byte, targetDir string) (*Changes, error) {
+	changes := &Changes{
+		Added:     make([]string, 0),
+		Removed:   make([]string, 0),
+		Modified:  make([]string, 0),
+		targetDir: targetDir,
+		files:     files,
+	}
+
+	existing := make(map[string]bool)
+	err := filepath.WalkDir(targetDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && filePath == targetDir {
+			// nothing built yet, everything is new
+			return filepath.SkipAll
+		} else if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		relPath, _ := filepath.Rel(targetDir, filePath)
+		relPath = filepath.ToSlash(relPath)
+		existing[relPath] = true
+
+		content, found := files[relPath]
+		if !found {
+			changes.Removed = append(changes.Removed, relPath)
+			return nil
+		}
+		// follows symlinks, in case the target was built by the dev server
+		previous, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(previous, content) {
+			changes.Modified = append(changes.Modified, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for relPath := range files {
+		if !existing[relPath] {
+			changes.Added = append(changes.Added, relPath)
+		}
+	}
+	slices.Sort(changes.Added)
+	slices.Sort(changes.Removed)
+	slices.Sort(changes.Modified)
+	return changes, nil
+}
+
+// Return true if the rendered site matches the target directory contents.
+func (changes *Changes) IsEmpty() bool {
+	return len(changes.Added)+len(changes.Removed)+len(changes.Modified) == 0
+}
+
+// Print the list of added, removed and modified files.
+func (changes *Changes) PrintSummary(out io.Writer) {
+	for _, relPath := range changes.Added {
+		fmt.Fprintln(out, "added   ", relPath)
+	}
+	for _, relPath := range changes.Removed {
+		fmt.Fprintln(out, "removed ", relPath)
+	}
+	for _, relPath := range changes.Modified {
+		fmt.Fprintln(out, "modified", relPath)
+	}
+	fmt.Fprintf(out, "%d added, %d removed, %d modified\n", len(changes.Added), len(changes.Removed), len(changes.Modified))
+}
+
+// Print unified diffs of the modified HTML and XML files. Their contents are normalized
+// before comparing, one tag or text node per line, so changes are readable even if minified.
+func (changes *Changes) PrintDiffs(out io.Writer) error {
+	for _, relPath := range changes.Modified {
+		extension := path.Ext(relPath)
+		if extension != ".html" && extension != ".xml" {
+			continue
+		}
+
+		targetPath := filepath.Join(changes.targetDir, filepath.FromSlash(relPath))
+		previous, err := os.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		before, err := normalizeMarkup(extension, previous)
+		if err != nil {
+			return fmt.Errorf("can't parse %s: %w", targetPath, err)
+		}
+		after, err := normalizeMarkup(extension, changes.files[relPath])
+		if err != nil {
+			return fmt.Errorf("can't parse %s: %w", relPath, err)
+		}
+		writeUnifiedDiff(out, "a/"+relPath, "b/"+relPath, before, after)
+	}
+	return nil
+}
+
+// Split the given html or xml content in lines, one per tag or text node,
+// so differences in whitespace or minifying don't affect the comparison.
+func normalizeMarkup(extension string, content []byte) ([]string, error) {
+	lines := make([]string, 0)
+	addText := func(text string) {
+		text = strings.Join(strings.Fields(text), " ")
+		if text != "" {
+			lines = append(lines, text)
+		}
+	}
+
+	if extension == ".xml" {
+		decoder := xml.NewDecoder(bytes.NewReader(content))
+		decoder.Strict = false
+		for {
+			offset := decoder.InputOffset()
+			token, err := decoder.RawToken()
+			if err == io.EOF {
+				return lines, nil
+			} else if err != nil {
+				return nil, err
+			}
+			if text, ok := token.(xml.CharData); ok {
+				addText(string(text))
+			} else {
+				addText(string(content[offset:decoder.InputOffset()]))
+			}
+		}
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return lines, nil
+			}
+			return nil, tokenizer.Err()
+		case html.TextToken:
+			addText(string(tokenizer.Text()))
+		default:
+			// re-render tags to normalize attribute quoting
+			addText(tokenizer.Token().String())
+		}
+	}
+}
+
+// An edit operation in a line diff: ' ' for unchanged lines, '-' for removed and '+' for added.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// Compute the shortest edit script between the given lines, using Myers' algorithm.
+func diffLines(a []string, b []string) []diffLine {
+	n, m := len(a), len(b)
+	maxSteps := n + m
+	offset := maxSteps + 1
+	v := make([]int, 2*maxSteps+3)
+	// the furthest reaching x for each diagonal before every step, used to backtrack the edit path
+	trace := make([][]int, 0)
+
+	var steps int
+search:
+	for d := 0; d <= maxSteps; d++ {
+		trace = append(trace, slices.Clone(v[offset-d-1:offset+d+2]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				steps = d
+				break search
+			}
+		}
+	}
+
+	// walk back from the end, recovering the edits of each step
+	result := make([]diffLine, 0, maxSteps)
+	x, y := n, m
+	for d := steps; d > 0; d-- {
+		// the trace window for step d covers diagonals -d-1..d+1
+		previous := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && previous(k-1) < previous(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := previous(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			result = append(result, diffLine{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			result = append(result, diffLine{'+', b[y-1]})
+			y--
+		} else {
+			result = append(result, diffLine{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		result = append(result, diffLine{' ', a[x-1]})
+		x--
+		y--
+	}
+	slices.Reverse(result)
+	return result
+}
+
+// Write the differences between the given lines in unified diff format.
+func writeUnifiedDiff(out io.Writer, nameA string, nameB string, a []string, b []string) {
+	lines := diffLines(a, b)
+	if !slices.ContainsFunc(lines, func(line diffLine) bool { return line.op != ' ' }) {
+		return
+	}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// line numbers in a and b at the start of each diff line
+	lineA, lineB := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, line := range lines {
+		lineA[i+1], lineB[i+1] = lineA[i], lineB[i]
+		if line.op != '+' {
+			lineA[i+1]++
+		}
+		if line.op != '-' {
+			lineB[i+1]++
+		}
+	}
+
+	for start := 0; start < len(lines); {
+		// find the next change and extend the hunk until there's enough unchanged lines after it
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for i := first; i < len(lines) && i-last <= 2*DIFF_CONTEXT_LINES; i++ {
+			if lines[i].op != ' ' {
+				last = i
+			}
+		}
+		from := max(first-DIFF_CONTEXT_LINES, start)
+		to := min(last+DIFF_CONTEXT_LINES+1, len(lines))
+
+		fmt.Fprintf(out, "@@ -%s +%s @@\n",
+			hunkRange(lineA[from], lineA[to]-lineA[from]),
+			hunkRange(lineB[from], lineB[to]-lineB[from]))
+		for _, line := range lines[from:to] {
+			fmt.Fprintf(out, "%c%s\n", line.op, line.text)
+		}
+		start = to
+	}
+}
+
+// Format a unified diff hunk range, given the 0-based start line and line count.
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
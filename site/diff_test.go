@@ -0,0 +1,119 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+
+	newFile(config.SrcDir, "index.html", `---
+title: home
+---
+<h1>{{ page.title }}</h1>
+<p>one</p>`)
+	newFile(config.SrcDir, "about.html", `---
+---
+<p>about</p>`)
+	newFile(config.SrcDir, "old.txt", "old")
+	err := Build(*config)
+	assertEqual(t, err, nil)
+
+	// nothing changed yet
+	_, changes, err := DryRun(*config)
+	assertEqual(t, err, nil)
+	assert(t, changes.IsEmpty())
+
+	os.Remove(filepath.Join(config.SrcDir, "old.txt"))
+	newFile(config.SrcDir, "new.txt", "new")
+	newFile(config.SrcDir, "index.html", `---
+title: home
+---
+<h1>{{ page.title }}</h1>
+<p>two</p>`)
+
+	_, changes, err = DryRun(*config)
+	assertEqual(t, err, nil)
+	assertEqual(t, strings.Join(changes.Added, ","), "new.txt")
+	assertEqual(t, strings.Join(changes.Removed, ","), "old.txt")
+	assertEqual(t, strings.Join(changes.Modified, ","), "index.html")
+
+	// the target is left untouched
+	_, err = os.Stat(filepath.Join(config.TargetDir, "old.txt"))
+	assertEqual(t, err, nil)
+	_, err = os.Stat(filepath.Join(config.TargetDir, "new.txt"))
+	assert(t, os.IsNotExist(err))
+
+	var output strings.Builder
+	err = changes.PrintDiffs(&output)
+	assertEqual(t, err, nil)
+	assertEqual(t, output.String(), `--- a/index.html
++++ b/index.html
+@@ -6,7 +6,7 @@
+ home
+ </h1>
+ <p>
+-one
++two
+ </p>
+ </body>
+ </html>
+`)
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := strings.Split("a b c d e f g h i j k l m n o p", " ")
+	b := strings.Split("a b x c d e f g h i j k l m o p q", " ")
+
+	var output strings.Builder
+	writeUnifiedDiff(&output, "a", "b", a, b)
+	assertEqual(t, output.String(), `--- a
++++ b
+@@ -1,5 +1,6 @@
+ a
+ b
++x
+ c
+ d
+ e
+@@ -11,6 +12,6 @@
+ k
+ l
+ m
+-n
+ o
+ p
++q
+`)
+
+	// no output for equal contents
+	output.Reset()
+	writeUnifiedDiff(&output, "a", "b", a, a)
+	assertEqual(t, output.String(), "")
+
+	// from and to empty contents
+	assertEqual(t, len(diffLines(nil, b)), len(b))
+	assertEqual(t, len(diffLines(a, nil)), len(a))
+}
+
+func TestNormalizeMarkup(t *testing.T) {
+	minified, _ := normalizeMarkup(".html", []byte(`<p class=title>hello <b>world</b></p>`))
+	expanded, _ := normalizeMarkup(".html", []byte(`<p class="title">
+  hello
+  <b>world</b>
+</p>`))
+	assertEqual(t, strings.Join(minified, "\n"), strings.Join(expanded, "\n"))
+
+	lines, err := normalizeMarkup(".xml", []byte(`<?xml version="1.0"?><feed><title>blog</title></feed>`))
+	assertEqual(t, err, nil)
+	assertEqual(t, strings.Join(lines, "\n"), `<?xml version="1.0"?>
+<feed>
+<title>
+blog
+</title>
+</feed>`)
+}
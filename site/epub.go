@@ -0,0 +1,503 @@
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/facundoolano/jorge/config"
+	"github.com/facundoolano/jorge/markup"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The subset of site posts to include in an ebook export.
+// Empty fields match any post, so the zero value selects all the site posts.
+type PostSelector struct {
+	Tag    string
+	Series string
+	Dir    string
+}
+
+func (selector PostSelector) matches(post map[string]interface{}) bool {
+	if selector.Tag != "" {
+		tags, _ := post["tags"].([]interface{})
+		if !slices.Contains(tags, interface{}(selector.Tag)) {
+			return false
+		}
+	}
+	if selector.Series != "" && post["series"] != selector.Series {
+		return false
+	}
+	if selector.Dir != "" {
+		dir := "/" + strings.Trim(filepath.ToSlash(selector.Dir), "/")
+		postDir, _ := post["dir"].(string)
+		if postDir != dir && !strings.HasPrefix(postDir, dir+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// An ebook chapter, rendered from a single site post.
+type epubChapter struct {
+	Id      string
+	Href    string
+	Title   string
+	Content string
+}
+
+// A local file (typically an image) embedded in the ebook.
+type epubResource struct {
+	Id        string
+	Href      string
+	MediaType string
+	SrcPath   string
+}
+
+type epubBook struct {
+	Id       string
+	Title    string
+	Author   string
+	Lang     string
+	Modified string
+	Cover    *epubResource
+	Chapters []epubChapter
+	// embedded resources indexed by their source path, to avoid adding them twice
+	Resources map[string]*epubResource
+}
+
+// Load the site project pointed by `config`, render the posts matching the selector
+// with the site's org/markdown pipeline and pack them as an EPUB 3 file at `outPath`.
+// The book metadata is taken from the `epub` key of the config file
+// (title, author, cover), falling back to the site name, author and language.
+func ExportEpub(config config.Config, selector PostSelector, outPath string) error {
+	site, err := load(config)
+	if err != nil {
+		return err
+	}
+
+	book := site.newEpubBook()
+
+	// books are read in chronological order, the opposite of site.posts
+	posts := slices.Clone(site.posts)
+	slices.Reverse(posts)
+	for _, post := range posts {
+		if !selector.matches(post) {
+			continue
+		}
+		srcPath := site.templatePath(post["src_path"].(string))
+		chapter, err := site.renderChapter(book, site.templates[srcPath], len(book.Chapters)+1)
+		if err != nil {
+			return fmt.Errorf("error in %s: %w", srcPath, err)
+		}
+		book.Chapters = append(book.Chapters, *chapter)
+	}
+
+	if len(book.Chapters) == 0 {
+		return fmt.Errorf("no posts found for the given selection")
+	}
+
+	// write to a temporary file first, so a failed export doesn't leave a broken book behind
+	tmpPath := outPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	if err := book.write(file); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s (%d chapters)\n", outPath, len(book.Chapters))
+	return nil
+}
+
+func (site *site) newEpubBook() *epubBook {
+	siteConfig := site.config.AsContext()
+	epubConfig, _ := siteConfig["epub"].(map[string]interface{})
+	lookup := func(key string) string {
+		if value, ok := epubConfig[key].(string); ok && value != "" {
+			return value
+		}
+		if value, ok := siteConfig[key].(string); ok {
+			return value
+		}
+		return ""
+	}
+
+	book := &epubBook{
+		Title:     lookup("title"),
+		Author:    lookup("author"),
+		Lang:      site.config.Lang,
+		Modified:  time.Now().UTC().Format(time.RFC3339),
+		Resources: make(map[string]*epubResource),
+	}
+	if book.Title == "" {
+		book.Title = lookup("name")
+	}
+	if lang, ok := epubConfig["lang"].(string); ok {
+		book.Lang = lang
+	}
+	if book.Lang == "" {
+		// dc:language is required by the spec
+		book.Lang = "en"
+	}
+
+	// derive a stable identifier from the site url and the book title, so re-exports
+	// of the same book are recognized as the same publication by readers.
+	hash := sha1.Sum([]byte(site.config.SiteUrl + book.Title))
+	book.Id = fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", hash[0:4], hash[4:6], hash[6:8], hash[8:10], hash[10:16])
+
+	if cover, ok := epubConfig["cover"].(string); ok && cover != "" {
+		srcPath := filepath.Join(site.config.SrcDir, cover)
+		mediaType, err := epubMediaType(srcPath)
+		if err != nil {
+			printWarnings(site.srcPath(srcPath), []string{err.Error()})
+		}
+		book.Cover = &epubResource{
+			Id:        "cover-image",
+			Href:      "images/cover" + filepath.Ext(cover),
+			MediaType: mediaType,
+			SrcPath:   srcPath,
+		}
+	}
+	return book
+}
+
+// Return the media type of the given file for the book manifest, based on its extension.
+// Unknown types fall back to a generic one, returned along with an error to report.
+func epubMediaType(path string) (string, error) {
+	if mediaType := mime.TypeByExtension(filepath.Ext(path)); mediaType != "" {
+		return mediaType, nil
+	}
+	return "application/octet-stream", fmt.Errorf("unknown media type of '%s'", filepath.Base(path))
+}
+
+func printWarnings(source string, warnings []string) {
+	for _, warning := range warnings {
+		fmt.Printf("warning: %s: %s\n", source, warning)
+	}
+}
+
+// Render the given post template content (without layouts) and convert it to an XHTML
+// chapter body, collecting the local images it references as book resources.
+func (site *site) renderChapter(book *epubBook, templ *markup.Template, number int) (*epubChapter, error) {
+	// discard the warnings of the post preview, they are found again when rendering the chapter
+	srcPath, _ := templ.Metadata["src_path"].(string)
+	site.takeWarnings(srcPath)
+	defer func() { printWarnings(srcPath, site.takeWarnings(srcPath)) }()
+
+	ctx := site.AsContext()
+	ctx["page"] = templ.Metadata
+	content, err := templ.RenderWith(ctx, site.config.HighlightTheme)
+	if err != nil {
+		return nil, err
+	}
+	contentReader, err := markup.Smartify(".html", bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(contentReader, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		body.AppendChild(node)
+	}
+	site.embedImages(book, body, templ.Metadata["url"].(string)+"/", srcPath)
+
+	var buf bytes.Buffer
+	for node := body.FirstChild; node != nil; node = node.NextSibling {
+		if err := html.Render(&buf, node); err != nil {
+			return nil, err
+		}
+	}
+
+	title, _ := templ.Metadata["title"].(string)
+	return &epubChapter{
+		Id:      fmt.Sprintf("chapter-%d", number),
+		Href:    fmt.Sprintf("chapter-%d.xhtml", number),
+		Title:   title,
+		Content: buf.String(),
+	}, nil
+}
+
+// Walk the given html node looking for images pointing to files in the site source,
+// add them to the book resources and rewrite their src to point to the embedded copy.
+// Scripts are removed since they aren't well-formed XHTML nor supported by most readers.
+func (site *site) embedImages(book *epubBook, node *html.Node, pageUrl string, pageSrcPath string) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode && child.Data == "script" {
+			node.RemoveChild(child)
+		} else {
+			site.embedImages(book, child, pageUrl, pageSrcPath)
+		}
+		child = next
+	}
+
+	if node.Type != html.ElementNode || node.Data != "img" {
+		return
+	}
+	for i, attr := range node.Attr {
+		if attr.Key != "src" {
+			continue
+		}
+		src, err := url.Parse(attr.Val)
+		if err != nil || src.IsAbs() || src.Host != "" || src.Path == "" {
+			continue
+		}
+		// resolve relative to the page url, to mimic what the browser would request
+		urlPath := src.Path
+		if !strings.HasPrefix(urlPath, "/") {
+			urlPath = path.Join(pageUrl, urlPath)
+		}
+		srcPath := filepath.Join(site.config.SrcDir, filepath.FromSlash(urlPath))
+		if _, err := os.Stat(srcPath); err != nil {
+			site.addWarning(pageSrcPath, fmt.Sprintf("image '%s' not found", src.Path))
+			continue
+		}
+
+		resource, found := book.Resources[srcPath]
+		if !found {
+			mediaType, err := epubMediaType(srcPath)
+			if err != nil {
+				site.addWarning(pageSrcPath, err.Error())
+			}
+			id := fmt.Sprintf("image-%d", len(book.Resources)+1)
+			resource = &epubResource{
+				Id:        id,
+				Href:      "images/" + id + filepath.Ext(srcPath),
+				MediaType: mediaType,
+				SrcPath:   srcPath,
+			}
+			book.Resources[srcPath] = resource
+		}
+		node.Attr[i].Val = resource.Href
+	}
+}
+
+// A file of the epub container, generated by executing a template with the given data.
+type epubFile struct {
+	name     string
+	template *template.Template
+	data     interface{}
+}
+
+// Write the book as a zipped EPUB container to the given writer.
+func (book *epubBook) write(writer io.Writer) error {
+	zipWriter := zip.NewWriter(writer)
+
+	// the mimetype file is required to be first and uncompressed
+	mimetype, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []epubFile{
+		{"META-INF/container.xml", epubContainerTemplate, book},
+		{"OEBPS/content.opf", epubPackageTemplate, book},
+		{"OEBPS/nav.xhtml", epubNavTemplate, book},
+		{"OEBPS/cover.xhtml", epubCoverTemplate, book},
+		{"OEBPS/style.css", epubStyleTemplate, book},
+	}
+	for _, chapter := range book.Chapters {
+		data := map[string]interface{}{"Book": book, "Chapter": chapter}
+		files = append(files, epubFile{"OEBPS/" + chapter.Href, epubChapterTemplate, data})
+	}
+
+	for _, file := range files {
+		fileWriter, err := zipWriter.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if err := file.template.Execute(fileWriter, file.data); err != nil {
+			return err
+		}
+	}
+
+	resources := book.SortedResources()
+	if book.Cover != nil {
+		resources = append(resources, book.Cover)
+	}
+	for _, resource := range resources {
+		fileWriter, err := zipWriter.Create("OEBPS/" + resource.Href)
+		if err != nil {
+			return err
+		}
+		srcFile, err := os.Open(resource.SrcPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fileWriter, srcFile)
+		srcFile.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+// Return the book embedded resources in a stable order.
+func (book *epubBook) SortedResources() []*epubResource {
+	resources := make([]*epubResource, 0, len(book.Resources))
+	for _, resource := range book.Resources {
+		resources = append(resources, resource)
+	}
+	slices.SortFunc(resources, func(a *epubResource, b *epubResource) int {
+		return strings.Compare(a.Href, b.Href)
+	})
+	return resources
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func newEpubTemplate(name string, text string) *template.Template {
+	funcs := template.FuncMap{"xml": xmlEscape}
+	return template.Must(template.New(name).Funcs(funcs).Parse(text))
+}
+
+var epubContainerTemplate = newEpubTemplate("container", `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`)
+
+var epubPackageTemplate = newEpubTemplate("package", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid" xml:lang="{{.Lang | xml}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">{{.Id}}</dc:identifier>
+    <dc:title>{{.Title | xml}}</dc:title>
+    {{- if .Author}}
+    <dc:creator>{{.Author | xml}}</dc:creator>
+    {{- end}}
+    <dc:language>{{.Lang | xml}}</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+    {{- if .Cover}}
+    <meta name="cover" content="{{.Cover.Id}}"/>
+    {{- end}}
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    {{- if .Cover}}
+    <item id="{{.Cover.Id}}" href="{{.Cover.Href}}" media-type="{{.Cover.MediaType}}" properties="cover-image"/>
+    {{- end}}
+    {{- range .Chapters}}
+    <item id="{{.Id}}" href="{{.Href}}" media-type="application/xhtml+xml"/>
+    {{- end}}
+    {{- range .SortedResources}}
+    <item id="{{.Id}}" href="{{.Href}}" media-type="{{.MediaType}}"/>
+    {{- end}}
+  </manifest>
+  <spine>
+    <itemref idref="cover"/>
+    <itemref idref="nav"/>
+    {{- range .Chapters}}
+    <itemref idref="{{.Id}}"/>
+    {{- end}}
+  </spine>
+</package>
+`)
+
+var epubNavTemplate = newEpubTemplate("nav", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="{{.Lang | xml}}" xml:lang="{{.Lang | xml}}">
+<head>
+  <title>{{.Title | xml}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{.Title | xml}}</h1>
+    <ol>
+      {{- range .Chapters}}
+      <li><a href="{{.Href}}">{{.Title | xml}}</a></li>
+      {{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`)
+
+var epubCoverTemplate = newEpubTemplate("cover", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="{{.Lang | xml}}" xml:lang="{{.Lang | xml}}">
+<head>
+  <title>{{.Title | xml}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body epub:type="cover">
+  {{- if .Cover}}
+  <img class="cover" src="{{.Cover.Href}}" alt="{{.Title | xml}}"/>
+  {{- else}}
+  <h1 class="cover-title">{{.Title | xml}}</h1>
+  {{- if .Author}}
+  <p class="cover-author">{{.Author | xml}}</p>
+  {{- end}}
+  {{- end}}
+</body>
+</html>
+`)
+
+var epubChapterTemplate = newEpubTemplate("chapter", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="{{.Book.Lang | xml}}" xml:lang="{{.Book.Lang | xml}}">
+<head>
+  <title>{{.Chapter.Title | xml}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <section epub:type="chapter">
+    <h1>{{.Chapter.Title | xml}}</h1>
+    {{.Chapter.Content}}
+  </section>
+</body>
+</html>
+`)
+
+var epubStyleTemplate = newEpubTemplate("style", `body { font-family: serif; line-height: 1.4; }
+h1, h2, h3 { font-family: sans-serif; }
+img { max-width: 100%; }
+img.cover { display: block; margin: 0 auto; max-height: 100%; }
+.cover-title { margin-top: 30%; text-align: center; }
+.cover-author { text-align: center; }
+pre { white-space: pre-wrap; font-size: 0.85em; }
+`)
@@ -0,0 +1,149 @@
+package site
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+func TestExportEpub(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+
+	imgDir := filepath.Join(config.SrcDir, "img")
+	os.Mkdir(imgDir, DIR_RWE_MODE)
+	newFile(imgDir, "photo.png", "not really a png")
+
+	content := `---
+title: hello world!
+date: 2024-01-01
+tags: [web]
+---
+<p>Hello world!<br></p>
+<img src="/img/photo.png">
+<script>alert("hi")</script>`
+	newFile(config.SrcDir, "hello.html", content)
+
+	content = `---
+title: goodbye!
+date: 2024-02-01
+tags: [web]
+---
+* Goodbye world!`
+	newFile(config.SrcDir, "goodbye.org", content)
+
+	content = `---
+title: off topic
+date: 2024-03-01
+tags: [music]
+---
+<p>excluded</p>`
+	newFile(config.SrcDir, "off-topic.html", content)
+
+	outPath := filepath.Join(config.RootDir, "book.epub")
+	err := ExportEpub(*config, PostSelector{Tag: "web"}, outPath)
+	assertEqual(t, err, nil)
+
+	reader, err := zip.OpenReader(outPath)
+	assertEqual(t, err, nil)
+	defer reader.Close()
+
+	// mimetype must be the first entry and stored uncompressed
+	assertEqual(t, reader.File[0].Name, "mimetype")
+	assertEqual(t, reader.File[0].Method, zip.Store)
+
+	files := make(map[string]string)
+	for _, file := range reader.File {
+		rc, _ := file.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(content)
+	}
+	assertEqual(t, files["mimetype"], "application/epub+zip")
+	assert(t, strings.Contains(files["META-INF/container.xml"], "OEBPS/content.opf"))
+
+	// chapters are in chronological order and only include the selected posts
+	opf := files["OEBPS/content.opf"]
+	assert(t, strings.Contains(opf, `<itemref idref="chapter-1"/>
+    <itemref idref="chapter-2"/>
+  </spine>`))
+	assert(t, strings.Contains(opf, `<item id="image-1" href="images/image-1.png" media-type="image/png"/>`))
+	assert(t, strings.Contains(files["OEBPS/nav.xhtml"], `<li><a href="chapter-1.xhtml">hello world!</a></li>
+      <li><a href="chapter-2.xhtml">goodbye!</a></li>`))
+	_, found := files["OEBPS/chapter-3.xhtml"]
+	assert(t, !found)
+
+	// chapter content is well-formed xhtml with local images embedded and scripts removed
+	chapter := files["OEBPS/chapter-1.xhtml"]
+	assert(t, strings.Contains(chapter, `<p>Hello world!<br/></p>`))
+	assert(t, strings.Contains(chapter, `<img src="images/image-1.png"/>`))
+	assert(t, !strings.Contains(chapter, "alert"))
+	assertEqual(t, files["OEBPS/images/image-1.png"], "not really a png")
+
+	// org content goes through the regular rendering pipeline
+	assert(t, strings.Contains(files["OEBPS/chapter-2.xhtml"], `<h1 id="goodbye-world">`))
+
+	err = ExportEpub(*config, PostSelector{Series: "missing"}, outPath)
+	assertEqual(t, err.Error(), "no posts found for the given selection")
+}
+
+func TestExportEpubErrors(t *testing.T) {
+	projectConfig := newProject()
+	defer os.RemoveAll(projectConfig.RootDir)
+	newFile(projectConfig.SrcDir, "hello.html", `---
+title: hello world!
+date: 2024-01-01
+---
+<p>Hello world!</p>
+<img src="/img/missing.png">
+<img src="/img/diagram.notatype">`)
+	os.MkdirAll(filepath.Join(projectConfig.SrcDir, "img"), DIR_RWE_MODE)
+	newFile(filepath.Join(projectConfig.SrcDir, "img"), "diagram.notatype", "not an image")
+	outPath := filepath.Join(projectConfig.RootDir, "book.epub")
+
+	// the book language defaults to english
+	newFile(projectConfig.RootDir, "config.yml", "lang: ''\n")
+	projectConfig, err := config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+	err = ExportEpub(*projectConfig, PostSelector{}, outPath)
+	assertEqual(t, err, nil)
+	opf := readEpubFile(outPath, "OEBPS/content.opf")
+	assert(t, strings.Contains(opf, `<dc:language>en</dc:language>`))
+
+	// missing images are skipped, and the ones of unknown type are embedded with a generic type
+	assert(t, strings.Contains(opf, `<item id="image-1" href="images/image-1.notatype" media-type="application/octet-stream"/>`))
+	assert(t, !strings.Contains(opf, `media-type=""`))
+	assert(t, strings.Contains(readEpubFile(outPath, "OEBPS/chapter-1.xhtml"), `<img src="/img/missing.png"/>`))
+
+	// a failed export leaves the previous book untouched
+	newFile(projectConfig.RootDir, "config.yml", "epub:\n  title: other\n  cover: img/missing.png\n")
+	projectConfig, err = config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+	err = ExportEpub(*projectConfig, PostSelector{}, outPath)
+	assert(t, os.IsNotExist(err))
+	opf = readEpubFile(outPath, "OEBPS/content.opf")
+	assert(t, strings.Contains(opf, `<dc:language>en</dc:language>`))
+	assert(t, !strings.Contains(opf, `<dc:title>other</dc:title>`))
+	_, err = os.Stat(outPath + ".tmp")
+	assert(t, os.IsNotExist(err))
+}
+
+func readEpubFile(epubPath string, name string) string {
+	reader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	rc, err := reader.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	content, _ := io.ReadAll(rc)
+	return string(content)
+}
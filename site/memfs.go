@@ -0,0 +1,153 @@
+package site
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A read-only file system with the files of a site built in memory, to be served with http.FS.
+// Linked static files are read from their source location when opened.
+type MemoryFS struct {
+	files map[string][]byte
+	links map[string]string
+	// the entries of each directory, keyed by its slash-separated path ("." for the root)
+	dirs    map[string][]fs.DirEntry
+	modTime time.Time
+	// the site redirect destinations, keyed by normalized url path
+	redirects map[string]string
+}
+
+// Create a file system with the contents of the given output, which shouldn't be written to anymore.
+func newMemoryFS(out *memoryOutput, redirects map[string]string) *MemoryFS {
+	memFS := &MemoryFS{
+		files:     out.files,
+		links:     out.links,
+		dirs:      map[string][]fs.DirEntry{".": {}},
+		modTime:   time.Now(),
+		redirects: redirects,
+	}
+
+	addEntry := func(name string, info fs.FileInfo) {
+		// add the missing parent directories, up to the root
+		for {
+			dir := path.Dir(name)
+			_, dirExists := memFS.dirs[dir]
+			memFS.dirs[dir] = append(memFS.dirs[dir], fs.FileInfoToDirEntry(info))
+			if dirExists {
+				return
+			}
+			name = dir
+			info = &memFileInfo{name: path.Base(dir), isDir: true, modTime: memFS.modTime}
+		}
+	}
+	for name, content := range memFS.files {
+		addEntry(name, &memFileInfo{name: path.Base(name), size: int64(len(content)), modTime: memFS.modTime})
+	}
+	for name, srcPath := range memFS.links {
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			// the source was removed after the build, it will fail when opened
+			info = &memFileInfo{name: path.Base(name), modTime: memFS.modTime}
+		}
+		addEntry(name, &linkFileInfo{FileInfo: info, name: path.Base(name)})
+	}
+	for _, entries := range memFS.dirs {
+		slices.SortFunc(entries, func(a fs.DirEntry, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
+	return memFS
+}
+
+func (memFS *MemoryFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if content, found := memFS.files[name]; found {
+		info := &memFileInfo{name: path.Base(name), size: int64(len(content)), modTime: memFS.modTime}
+		return &memFile{Reader: bytes.NewReader(content), info: info}, nil
+	}
+	if srcPath, found := memFS.links[name]; found {
+		return os.Open(srcPath)
+	}
+	if entries, found := memFS.dirs[name]; found {
+		info := &memFileInfo{name: path.Base(name), isDir: true, modTime: memFS.modTime}
+		return &memDir{info: info, entries: entries}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Return the url that the given path redirects to, if any. Trailing slashes are ignored.
+func (memFS *MemoryFS) Redirect(urlPath string) (string, bool) {
+	to, found := memFS.redirects[normalizeUrlPath(urlPath)]
+	return to, found
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	isDir   bool
+	modTime time.Time
+}
+
+func (info *memFileInfo) Name() string       { return info.name }
+func (info *memFileInfo) Size() int64        { return info.size }
+func (info *memFileInfo) ModTime() time.Time { return info.modTime }
+func (info *memFileInfo) IsDir() bool        { return info.isDir }
+func (info *memFileInfo) Sys() any           { return nil }
+func (info *memFileInfo) Mode() fs.FileMode {
+	if info.isDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// The info of a linked source file, named as its target path.
+type linkFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (info *linkFileInfo) Name() string { return info.name }
+
+type memFile struct {
+	*bytes.Reader
+	info *memFileInfo
+}
+
+func (file *memFile) Stat() (fs.FileInfo, error) { return file.info, nil }
+func (file *memFile) Close() error               { return nil }
+
+type memDir struct {
+	info    *memFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (dir *memDir) Stat() (fs.FileInfo, error) { return dir.info, nil }
+func (dir *memDir) Close() error               { return nil }
+func (dir *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.info.name, Err: fs.ErrInvalid}
+}
+
+// Return the next count entries of the directory, or all of the remaining ones if count <= 0.
+func (dir *memDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := dir.entries[dir.offset:]
+	if count <= 0 {
+		dir.offset = len(dir.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(remaining))
+	dir.offset += count
+	return remaining[:count], nil
+}
@@ -0,0 +1,115 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The destination of the files written by a build.
+// Paths are relative to the output root.
+type output interface {
+	MkdirAll(path string) error
+	// write the contents of the reader to the given path, returning the number of bytes written
+	WriteFile(path string, content io.Reader) (int64, error)
+	// add the given source file to the output without copying it, if supported
+	Link(srcPath string, path string) error
+}
+
+// Writes the build files to a directory on disk.
+type dirOutput struct {
+	root string
+	// the directory reported as destination in the log messages,
+	// which can differ from the root while building into a staging directory
+	displayRoot string
+}
+
+func (out dirOutput) MkdirAll(path string) error {
+	return os.MkdirAll(filepath.Join(out.root, path), DIR_RWE_MODE)
+}
+
+func (out dirOutput) WriteFile(path string, content io.Reader) (int64, error) {
+	size, err := writeToFile(filepath.Join(out.root, path), content)
+	if err == nil {
+		fmt.Println("wrote", filepath.Join(out.displayRoot, path))
+	}
+	return size, err
+}
+
+// Symlink the given source file instead of copying it.
+func (out dirOutput) Link(srcPath string, path string) error {
+	abs, _ := filepath.Abs(srcPath)
+	return os.Symlink(abs, filepath.Join(out.root, path))
+}
+
+// Keeps the build files in memory, keyed by their slash-separated path.
+type memoryOutput struct {
+	mutex sync.Mutex
+	files map[string][]byte
+	// the source paths of linked static files, which are read from disk when needed
+	links map[string]string
+}
+
+func newMemoryOutput() *memoryOutput {
+	return &memoryOutput{files: make(map[string][]byte), links: make(map[string]string)}
+}
+
+func (out *memoryOutput) MkdirAll(path string) error {
+	return nil
+}
+
+func (out *memoryOutput) WriteFile(path string, content io.Reader) (int64, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return 0, err
+	}
+	out.mutex.Lock()
+	defer out.mutex.Unlock()
+	out.files[filepath.ToSlash(path)] = data
+	return int64(len(data)), nil
+}
+
+// Keep a reference to the source file instead of reading it into memory.
+func (out *memoryOutput) Link(srcPath string, path string) error {
+	out.mutex.Lock()
+	defer out.mutex.Unlock()
+	out.links[filepath.ToSlash(path)] = srcPath
+	return nil
+}
+
+// Writes the build files to several outputs.
+type teeOutput []output
+
+func (outputs teeOutput) MkdirAll(path string) error {
+	for _, out := range outputs {
+		if err := out.MkdirAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (outputs teeOutput) WriteFile(path string, content io.Reader) (int64, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return 0, err
+	}
+	for _, out := range outputs {
+		if _, err := out.WriteFile(path, bytes.NewReader(data)); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(data)), nil
+}
+
+func (outputs teeOutput) Link(srcPath string, path string) error {
+	for _, out := range outputs {
+		if err := out.Link(srcPath, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/facundoolano/jorge/config"
+)
+
+// A summary of a site template, as listed by the development server.
+type PageInfo struct {
+	Title string
+	Url   string
+	// the path of the template, relative to the project root
+	Source string
+	// the date from the front matter, zero for pages without one
+	Date     time.Time
+	Draft    bool
+	Modified time.Time
+}
+
+// Load the site and return a summary of the templates it would render, including
+// drafts regardless of the config, sorted by source path.
+func ListPages(config config.Config) ([]PageInfo, error) {
+	site, err := load(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]PageInfo, 0, len(site.templates))
+	for path, templ := range site.templates {
+		if !templ.IsPublished() {
+			continue
+		}
+		relPath, _ := filepath.Rel(site.config.SrcDir, path)
+		if collection := site.findCollection(relPath); collection != nil && !collection.Output {
+			continue
+		}
+
+		page := PageInfo{
+			Url:    templ.Metadata["url"].(string),
+			Source: site.srcPath(path),
+			Draft:  templ.IsDraft(),
+		}
+		page.Title, _ = templ.Metadata["title"].(string)
+		page.Date, _ = templ.Metadata["date"].(time.Time)
+		if info, err := os.Stat(path); err == nil {
+			page.Modified = info.ModTime()
+		}
+		pages = append(pages, page)
+	}
+	slices.SortFunc(pages, func(a PageInfo, b PageInfo) int {
+		return strings.Compare(a.Source, b.Source)
+	})
+	return pages, nil
+}
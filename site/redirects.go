@@ -0,0 +1,102 @@
+package site
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/facundoolano/jorge/markup"
+)
+
+// A path of the site that redirects to another url.
+type redirect struct {
+	// the url to redirect to, either absolute or a path within the site
+	to string
+	// where the redirect was declared, relative to the project root
+	source string
+}
+
+// Collect the redirects declared under the `redirects` key of config.yml and in the
+// `redirect_from` front matter of templates, keyed by their normalized path, for the
+// development server to apply them. Production servers need equivalent rules.
+func (site *site) loadRedirects() error {
+	site.redirects = make(map[string]redirect)
+	add := func(from string, to redirect) error {
+		from = normalizeUrlPath(from)
+		if previous, found := site.redirects[from]; found {
+			return fmt.Errorf("redirect from %s declared both in %s and %s", from, previous.source, to.source)
+		}
+		site.redirects[from] = to
+		return nil
+	}
+
+	for _, from := range sortedKeys(site.config.Redirects) {
+		if err := add(from, redirect{to: site.config.Redirects[from], source: "config.yml"}); err != nil {
+			return err
+		}
+	}
+
+	// sorted, so conflicts are reported consistently
+	for _, path := range sortedKeys(site.templates) {
+		templ := site.templates[path]
+		if !templ.IsPublished() || (templ.IsDraft() && !site.config.IncludeDrafts) {
+			continue
+		}
+		paths, err := redirectFrom(templ)
+		if err != nil {
+			return fmt.Errorf("%s: %w", site.srcPath(path), err)
+		}
+		for _, from := range paths {
+			to := redirect{to: templ.Metadata["url"].(string), source: site.srcPath(path)}
+			if err := add(from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Return the paths listed in the `redirect_from` front matter of the template, either a string or a list.
+func redirectFrom(templ *markup.Template) ([]string, error) {
+	switch value := templ.Metadata["redirect_from"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		paths := make([]string, 0, len(value))
+		for _, item := range value {
+			itemPath, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("redirect_from expected a list of paths, got %v", item)
+			}
+			paths = append(paths, itemPath)
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("redirect_from expected a path or a list of paths, got %v", value)
+	}
+}
+
+// Return the destinations of the site redirects, keyed by their normalized path.
+func (site *site) redirectMap() map[string]string {
+	redirects := make(map[string]string)
+	for from, redirect := range site.redirects {
+		redirects[from] = redirect.to
+	}
+	return redirects
+}
+
+// Clean the given url path, removing the trailing slash, so it can be compared with others.
+func normalizeUrlPath(urlPath string) string {
+	return path.Clean("/" + urlPath)
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,156 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A summary of a site build, listing the files written to the target directory
+// and the errors found in the process.
+type BuildReport struct {
+	Started  time.Time     `json:"started"`
+	Duration float64       `json:"duration_ms"`
+	Files    []*FileReport `json:"files"`
+	Errors   []BuildIssue  `json:"errors"`
+
+	mutex sync.Mutex
+}
+
+// The details of a file written to the target directory.
+type FileReport struct {
+	// the file path, relative to the target directory
+	Path string `json:"path"`
+	// the path of the file it was built from, relative to the project root
+	Source string `json:"source"`
+	Size   int64  `json:"size"`
+	// the time spent rendering the template and its layouts, zero for static files
+	RenderTime float64 `json:"render_ms"`
+	Template   bool    `json:"template"`
+	Minified   bool    `json:"minified"`
+	Smartified bool    `json:"smartified"`
+	// whether precompressed versions of the file were written next to it
+	Compressed bool     `json:"compressed"`
+	Warnings   []string `json:"warnings"`
+}
+
+// A problem found while building a file.
+type BuildIssue struct {
+	// the path of the file being built, relative to the project root. Empty for site-wide problems.
+	Source string `json:"source"`
+	// the file where the problem was found, when different from the source (e.g. a layout)
+	File string `json:"file,omitempty"`
+	// the line of the problem within the file, when known
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// Return the issue location, as path:line.
+func (issue BuildIssue) Location() string {
+	location := issue.Source
+	if issue.File != "" {
+		location = fmt.Sprintf("%s (%s)", issue.Source, issue.File)
+	}
+	if issue.Line != 0 {
+		location = fmt.Sprintf("%s:%d", location, issue.Line)
+	}
+	return location
+}
+
+func newBuildReport() *BuildReport {
+	return &BuildReport{
+		Started: time.Now(),
+		Files:   make([]*FileReport, 0),
+		Errors:  make([]BuildIssue, 0),
+	}
+}
+
+func (report *BuildReport) addFile(file *FileReport) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Files = append(report.Files, file)
+}
+
+func (report *BuildReport) addError(issue BuildIssue) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Errors = append(report.Errors, issue)
+}
+
+// Return the warnings found in the built files.
+func (report *BuildReport) Warnings() []BuildIssue {
+	warnings := make([]BuildIssue, 0)
+	for _, file := range report.Files {
+		for _, warning := range file.Warnings {
+			warnings = append(warnings, BuildIssue{Source: file.Source, Message: warning})
+		}
+	}
+	return warnings
+}
+
+// Print the errors and warnings found in the built files, if any.
+// Site-wide errors are left out, since they are returned by the build.
+func (report *BuildReport) printIssues() {
+	printList := func(label string, issues []BuildIssue) {
+		issues = slices.DeleteFunc(slices.Clone(issues), func(issue BuildIssue) bool {
+			return issue.Source == ""
+		})
+		if len(issues) == 0 {
+			return
+		}
+		fmt.Printf("%d %s:\n", len(issues), plural(label, len(issues)))
+		for _, issue := range issues {
+			fmt.Printf("  %s: %s\n", issue.Location(), issue.Message)
+		}
+	}
+	printList("error", report.Errors)
+	printList("warning", report.Warnings())
+}
+
+// Return an error if the build should be considered failed: when some file failed to render,
+// unless keepGoing is set, or when there are warnings and failOnWarning is set.
+func (report *BuildReport) check(keepGoing bool, failOnWarning bool) error {
+	if count := len(report.Errors); count > 0 && !keepGoing {
+		return fmt.Errorf("build failed with %d %s", count, plural("error", count))
+	}
+	if count := len(report.Warnings()); count > 0 && failOnWarning {
+		return fmt.Errorf("build failed with %d %s", count, plural("warning", count))
+	}
+	return nil
+}
+
+func plural(word string, count int) string {
+	if count == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// Set the build duration and sort the files, which are added in no particular order by the build workers.
+func (report *BuildReport) finish() {
+	report.Duration = milliseconds(time.Since(report.Started))
+	slices.SortFunc(report.Files, func(a *FileReport, b *FileReport) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+}
+
+// Write the report as JSON to the given path.
+func (report *BuildReport) WriteJSON(path string) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), DIR_RWE_MODE); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), FILE_RW_MODE)
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
@@ -2,6 +2,7 @@ package site
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -16,7 +17,6 @@ import (
 
 	"github.com/facundoolano/jorge/config"
 	"github.com/facundoolano/jorge/markup"
-	"gopkg.in/yaml.v3"
 )
 
 const FILE_RW_MODE = 0666
@@ -29,24 +29,77 @@ type site struct {
 	pages        []map[string]interface{}
 	static_files []map[string]interface{}
 	tags         map[string][]map[string]interface{}
+	collections  map[string][]map[string]interface{}
 	data         map[string]interface{}
 
 	templateEngine *markup.Engine
 	templates      map[string]*markup.Template
 
 	minifier markup.Minifier
+
+	// the details of the last build
+	report *BuildReport
+	// where the build files are written, e.g. a staging directory that will replace the target
+	output output
+	// an additional destination for the files written to the target directory, if any
+	extraOutput output
+
+	redirects map[string]redirect
+
+	// the non-fatal problems found while rendering each template, by its src_path
+	warnings      map[string][]string
+	warningsMutex sync.Mutex
 }
 
 // Load the site project pointed by `config`, then walk `config.SrcDir`
 // and recreate it at `config.TargetDir` by rendering template files and copying static ones.
-// The previous target dir contents are deleted.
+// The previous target dir contents are replaced once the build succeeds.
 func Build(config config.Config) error {
+	_, err := BuildWithReport(config)
+	return err
+}
+
+// Build the site as `Build` does, returning a report of the written files and errors found.
+// The report is nil if the site project couldn't be loaded.
+func BuildWithReport(config config.Config) (*BuildReport, error) {
 	site, err := load(config)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	err = site.build()
+	site.report.finish()
+	site.report.printIssues()
+	return site.report, err
+}
+
+// Build the site into memory, returning a file system with its files, for the development
+// server to serve them without going through the target directory. If writeTarget is set,
+// the files are also written to the target directory, as `Build` does.
+// The file system is nil if the build failed.
+func BuildInMemory(config config.Config, writeTarget bool) (*BuildReport, *MemoryFS, error) {
+	site, err := load(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	// redirects are only honored by the development server
+	if err := site.loadRedirects(); err != nil {
+		return nil, nil, err
 	}
 
-	return site.build()
+	memory := newMemoryOutput()
+	if writeTarget {
+		site.extraOutput = memory
+		err = site.build()
+	} else {
+		err = site.buildInMemory(memory)
+	}
+	site.report.finish()
+	site.report.printIssues()
+	if err != nil {
+		return site.report, nil, err
+	}
+	return site.report, newMemoryFS(memory, site.redirectMap()), nil
 }
 
 // Parse and render the given liquid expression, eg. " site.posts | map:title "
@@ -63,13 +116,15 @@ func EvalMetadata(config config.Config, expression string) (string, error) {
 // pointed by `config`, loading layouts, templates and data files.
 func load(config config.Config) (*site, error) {
 	site := site{
-		layouts:        make(map[string]markup.Template),
-		templates:      make(map[string]*markup.Template),
-		config:         config,
-		tags:           make(map[string][]map[string]interface{}),
-		data:           make(map[string]interface{}),
-		templateEngine: markup.NewEngine(config.SiteUrl, config.IncludesDir),
+		layouts:     make(map[string]markup.Template),
+		templates:   make(map[string]*markup.Template),
+		config:      config,
+		tags:        make(map[string][]map[string]interface{}),
+		collections: make(map[string][]map[string]interface{}),
+		data:        make(map[string]interface{}),
+		warnings:    make(map[string][]string),
 	}
+	site.templateEngine = markup.NewEngine(config.SiteUrl, config.IncludesDir, site.addRenderWarning)
 
 	if err := site.loadDataFiles(); err != nil {
 		return nil, err
@@ -118,44 +173,87 @@ func (site *site) loadLayouts() error {
 	return nil
 }
 
+// Load the files in the data directory, decoding them according to their extension
+// (see `loadDataFile`) and making them available as site.data.<filename>.
+// Files in subdirectories are nested accordingly, e.g. data/team/members.csv becomes
+// site.data.team.members.
 func (site *site) loadDataFiles() error {
-	files, err := os.ReadDir(site.config.DataDir)
-
-	if os.IsNotExist(err) {
+	if _, err := os.Stat(site.config.DataDir); os.IsNotExist(err) {
 		return nil
-	} else if err != nil {
-		return err
 	}
 
-	for _, entry := range files {
-		if !entry.IsDir() {
-			filename := entry.Name()
-			path := filepath.Join(site.config.DataDir, filename)
+	return filepath.WalkDir(site.config.DataDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && path != site.config.DataDir {
+			// skip dot files and directories
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
 
-			yamlContent, err := os.ReadFile(path)
-			if err != nil {
-				return err
+		data, supported, err := loadDataFile(path)
+		if err != nil {
+			return err
+		}
+		if !supported {
+			fmt.Println("skipping unsupported data file", path)
+			return nil
+		}
+
+		// walk down the data map creating a nested map for each subdirectory
+		relPath, _ := filepath.Rel(site.config.DataDir, path)
+		parent := site.data
+		for _, dir := range strings.Split(filepath.Dir(relPath), string(filepath.Separator)) {
+			if dir == "." {
+				continue
 			}
-			var data interface{}
-			err = yaml.Unmarshal(yamlContent, &data)
-			if err != nil {
-				return fmt.Errorf("invalid yaml format: File '%s', %w", path, err)
+			if _, found := parent[dir]; !found {
+				parent[dir] = make(map[string]interface{})
 			}
-
-			data_name := strings.TrimSuffix(filename, filepath.Ext(filename))
-			site.data[data_name] = data
+			nested, ok := parent[dir].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("data directory '%s' conflicts with a data file of the same name", dir)
+			}
+			parent = nested
 		}
-	}
 
-	return nil
+		dataName := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, found := parent[dataName]; found {
+			return fmt.Errorf("data file '%s' conflicts with another data file or directory of the same name", path)
+		}
+		parent[dataName] = data
+		return nil
+	})
 }
 
 func (site *site) loadTemplates() error {
 	if _, err := os.Stat(site.config.SrcDir); err != nil {
 		return fmt.Errorf("missing src directory")
 	}
+	for _, collection := range site.config.Collections {
+		site.collections[collection.Name] = make([]map[string]interface{}, 0)
+	}
+	// the src path of the collection items by target path, to detect conflicting permalinks
+	permalinks := make(map[string]string)
 
 	err := filepath.WalkDir(site.config.SrcDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(site.config.SrcDir, path)
+		if site.config.IsExcluded(relPath, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		if !entry.IsDir() {
 			templ, err := markup.Parse(site.templateEngine, path)
 			// if something fails skip
@@ -163,7 +261,6 @@ func (site *site) loadTemplates() error {
 				return checkFileError(err)
 			}
 
-			relPath, _ := filepath.Rel(site.config.SrcDir, path)
 			baseName := strings.TrimSuffix(filepath.Base(relPath), filepath.Ext(relPath))
 
 			// if it's a static file, treat separately
@@ -179,11 +276,34 @@ func (site *site) loadTemplates() error {
 				return nil
 			}
 
-			srcPath, _ := filepath.Rel(site.config.RootDir, path)
+			// front matter explicit values take precedence over the defaults from config
+			site.config.ApplyDefaults(relPath, templ.Metadata)
+			if _, err := markup.NormalizeMetadata(templ.Metadata); err != nil {
+				return fmt.Errorf("invalid front matter defaults for %s: %w", site.srcPath(path), err)
+			}
+
+			srcPath := site.srcPath(path)
 			targetPath := strings.TrimSuffix(relPath, filepath.Ext(relPath)) + templ.TargetExt()
 			if templ.TargetExt() == ".html" && baseName != "index" {
 				targetPath = filepath.Join(strings.TrimSuffix(relPath, filepath.Ext(relPath)), "index.html")
 			}
+
+			// templates in a collection can override their default target path and layout
+			collection := site.findCollection(relPath)
+			if collection != nil {
+				templ.Metadata["collection"] = collection.Name
+				date, _ := templ.Metadata["date"].(time.Time)
+				permalink, err := collection.TargetPath(relPath, templ.TargetExt(), date)
+				if err != nil {
+					return fmt.Errorf("invalid permalink for collection item %s: %w", srcPath, err)
+				}
+				if permalink != "" {
+					targetPath = permalink
+				}
+				if _, ok := templ.Metadata["layout"]; !ok && collection.Layout != "" {
+					templ.Metadata["layout"] = collection.Layout
+				}
+			}
 			templ.Metadata["src_path"] = srcPath
 			templ.Metadata["path"] = targetPath
 			templ.Metadata["url"] = "/" + strings.TrimSuffix(strings.TrimSuffix(targetPath, "/index.html"), ".html")
@@ -192,21 +312,29 @@ func (site *site) loadTemplates() error {
 
 			// if drafts are disabled, exclude from posts, page and tags indexes, but not from site.templates
 			// we want to explicitly exclude the template from the target, rather than treating it as a non template file
-			if !templ.IsDraft() || site.config.IncludeDrafts {
+			// unpublished templates are always excluded, the same way
+			if templ.IsPublished() && (!templ.IsDraft() || site.config.IncludeDrafts) {
 				// posts are templates that can be chronologically sorted --that have a date.
-				// the rest are pages.
-				if templ.IsPost() {
-
-					templ.Metadata["content"], templ.Metadata["excerpt"] = getPreviewContent(templ)
-					site.posts = append(site.posts, templ.Metadata)
+				// the rest are pages. Templates in user defined collections are indexed separately.
+				if collection != nil {
+					if templ.TargetExt() == ".html" {
+						templ.Metadata["content"], templ.Metadata["excerpt"] = getPreviewContent(templ)
+					}
+					site.collections[collection.Name] = append(site.collections[collection.Name], templ.Metadata)
 
-					// also add to tags index
-					if tags, ok := templ.Metadata["tags"]; ok {
-						for _, tag := range tags.([]interface{}) {
-							tag := tag.(string)
-							site.tags[tag] = append(site.tags[tag], templ.Metadata)
+					// only the items written to the target have a page to link to
+					if collection.Output {
+						if other, found := permalinks[targetPath]; found {
+							return fmt.Errorf("collection items %s and %s have the same permalink %s", other, srcPath, templ.Metadata["url"])
 						}
+						permalinks[targetPath] = srcPath
+						site.indexTags(templ.Metadata)
 					}
+				} else if templ.IsPost() {
+
+					templ.Metadata["content"], templ.Metadata["excerpt"] = getPreviewContent(templ)
+					site.posts = append(site.posts, templ.Metadata)
+					site.indexTags(templ.Metadata)
 
 				} else if baseName != "index" {
 					// the index pages should be skipped from the page directory
@@ -240,19 +368,97 @@ func (site *site) loadTemplates() error {
 		slices.SortFunc(posts, CompareTemplates)
 	}
 
+	for _, collection := range site.config.Collections {
+		items := site.collections[collection.Name]
+		slices.SortStableFunc(items, func(a map[string]interface{}, b map[string]interface{}) int {
+			result := compareValues(a[collection.SortBy], b[collection.SortBy])
+			if result == 0 {
+				result = strings.Compare(a["path"].(string), b["path"].(string))
+			}
+			if collection.Reverse {
+				return -result
+			}
+			return result
+		})
+	}
+
 	// populate previous and next in template index
-	site.addPrevNext(site.pages)
-	site.addPrevNext(site.posts)
+	site.addPrevNext(site.pages, true)
+	site.addPrevNext(site.posts, true)
+	for _, items := range site.collections {
+		site.addPrevNext(items, false)
+	}
 
 	return nil
 }
 
-func (site *site) addPrevNext(posts []map[string]interface{}) {
+// Add the given post or collection item to the index of each of its tags.
+func (site *site) indexTags(metadata map[string]interface{}) {
+	tags, _ := metadata["tags"].([]interface{})
+	for _, tag := range tags {
+		if tag, ok := tag.(string); ok {
+			site.tags[tag] = append(site.tags[tag], metadata)
+		}
+	}
+}
+
+// Return the first collection declared in the config that contains the given src relative path,
+// or nil if there's none.
+func (site *site) findCollection(relPath string) *config.Collection {
+	for i, collection := range site.config.Collections {
+		if collection.Contains(relPath) {
+			return &site.config.Collections[i]
+		}
+	}
+	return nil
+}
+
+// Compare two front matter values of the same type (dates, strings and numbers),
+// sorting missing or incomparable values last.
+func compareValues(a interface{}, b interface{}) int {
+	if a == nil || b == nil {
+		if a == b {
+			return 0
+		} else if a == nil {
+			return 1
+		}
+		return -1
+	}
+
+	switch a := a.(type) {
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b)
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case int:
+		if b, ok := b.(int); ok {
+			return a - b
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			if a < b {
+				return -1
+			} else if a > b {
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// Populate the previous and next keys of the given sorted list of templates.
+// If `sameDir` is true, only link templates that share a directory.
+func (site *site) addPrevNext(posts []map[string]interface{}, sameDir bool) {
 	for i, post := range posts {
-		path := filepath.Join(site.config.RootDir, post["src_path"].(string))
+		path := site.templatePath(post["src_path"].(string))
 
 		// only consider them part of the same collection if they share the directory
-		if i > 0 && post["dir"] == posts[i-1]["dir"] {
+		if i > 0 && (!sameDir || post["dir"] == posts[i-1]["dir"]) {
 			// make a copy of the map, without prev/next (to avoid weird recursion)
 			previous := maps.Clone(posts[i-1])
 			delete(previous, "previous")
@@ -260,7 +466,7 @@ func (site *site) addPrevNext(posts []map[string]interface{}) {
 			site.templates[path].Metadata["previous"] = previous
 		}
 
-		if i < len(posts)-1 && post["dir"] == posts[i+1]["dir"] {
+		if i < len(posts)-1 && (!sameDir || post["dir"] == posts[i+1]["dir"]) {
 			next := maps.Clone(posts[i+1])
 			delete(next, "previous")
 			delete(next, "next")
@@ -269,31 +475,112 @@ func (site *site) addPrevNext(posts []map[string]interface{}) {
 	}
 }
 
+// Return the path of the template file with the given `src_path` metadata value,
+// as used for the site.templates keys.
+func (site *site) templatePath(srcPath string) string {
+	if filepath.IsAbs(srcPath) {
+		return srcPath
+	}
+	return filepath.Join(site.config.RootDir, srcPath)
+}
+
+// Return an error if the target directory overlaps with the project or source directories,
+// since its contents are deleted on every build.
+func (site *site) checkTargetDir() error {
+	target, _ := filepath.Abs(site.config.TargetDir)
+	root, _ := filepath.Abs(site.config.RootDir)
+	src, _ := filepath.Abs(site.config.SrcDir)
+	if isWithin(target, root) || isWithin(target, src) || isWithin(src, target) {
+		return fmt.Errorf("invalid destination directory '%s': it can't contain the project or overlap with the source directory", site.config.TargetDir)
+	}
+	return nil
+}
+
+// Report whether path is dir or is located inside of it.
+func isWithin(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // Walk the `site.Config.SrcDir` directory and reproduce it at `site.Config.TargetDir`,
 // rendering template files and copying static ones.
+// The files are written to a staging directory that replaces the target only if the build succeeds,
+// so the previous target contents are left untouched on failure.
 func (site *site) build() error {
-	// clear previous target contents
-	os.RemoveAll(site.config.TargetDir)
+	site.report = newBuildReport()
+	fail := func(err error) error {
+		site.report.addError(BuildIssue{Message: err.Error()})
+		return err
+	}
+
+	if err := site.checkTargetDir(); err != nil {
+		return fail(err)
+	}
+
+	// create the staging dir next to the target, to ensure they are in the same file system
+	targetParent := filepath.Dir(filepath.Clean(site.config.TargetDir))
+	if err := os.MkdirAll(targetParent, DIR_RWE_MODE); err != nil {
+		return fail(err)
+	}
+	// not using os.MkdirTemp since it ignores the umask, and the dir will end up as the target
+	stagingName := fmt.Sprintf(".%s-staging-%d-%d", filepath.Base(site.config.TargetDir), os.Getpid(), time.Now().UnixNano())
+	stagingDir := filepath.Join(targetParent, stagingName)
+	if err := os.Mkdir(stagingDir, DIR_RWE_MODE); err != nil {
+		return fail(err)
+	}
+	// after a successful swap, this is removing the previous target contents
+	defer os.RemoveAll(stagingDir)
+	site.output = dirOutput{root: stagingDir, displayRoot: site.config.TargetDir}
+	if site.extraOutput != nil {
+		site.output = teeOutput{site.output, site.extraOutput}
+	}
+
+	if err := site.writeFiles(); err != nil {
+		return fail(err)
+	}
+	if err := site.report.check(site.config.KeepGoing, site.config.FailOnWarning); err != nil {
+		return err
+	}
+	if err := swapDirs(stagingDir, site.config.TargetDir); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Render the site files into the given memory output, leaving the target directory untouched.
+func (site *site) buildInMemory(memory *memoryOutput) error {
+	site.report = newBuildReport()
+	site.output = memory
+	if err := site.writeFiles(); err != nil {
+		site.report.addError(BuildIssue{Message: err.Error()})
+		return err
+	}
+	return site.report.check(site.config.KeepGoing, site.config.FailOnWarning)
+}
 
+// Walk the source directory, creating directories and sending files to the build workers
+// to write them at the output directory.
+func (site *site) writeFiles() error {
 	wg, files := spawnBuildWorkers(site)
 	defer wg.Wait()
 	defer close(files)
 
-	// walk the source directory, creating directories and files at the target dir
 	return filepath.WalkDir(site.config.SrcDir, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if strings.HasPrefix(filepath.Base(path), ".") {
-			// skip dot files and directories
+		subpath, _ := filepath.Rel(site.config.SrcDir, path)
+		if site.config.IsExcluded(subpath, entry.IsDir()) {
+			// skip dot files and the ones excluded by config
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		subpath, _ := filepath.Rel(site.config.SrcDir, path)
-		targetPath := filepath.Join(site.config.TargetDir, subpath)
 
 		// if it's a directory, just create the same at the target
 		if entry.IsDir() {
-			return os.MkdirAll(targetPath, DIR_RWE_MODE)
+			return site.output.MkdirAll(subpath)
 		}
 		// if it's a file (either static or template) send the path to a worker to build in target
 		files <- path
@@ -312,9 +599,12 @@ func spawnBuildWorkers(site *site) (*sync.WaitGroup, chan string) {
 		go func(files <-chan string) {
 			defer wg.Done()
 			for path := range files {
-				err := site.buildFile(path)
+				file, err := site.buildFile(path)
 				if err != nil {
-					fmt.Printf("error in %s: %s\n", path, err)
+					site.report.addError(site.newIssue(path, err))
+				}
+				if file != nil {
+					site.report.addFile(file)
 				}
 			}
 		}(files)
@@ -322,9 +612,13 @@ func spawnBuildWorkers(site *site) (*sync.WaitGroup, chan string) {
 	return &wg, files
 }
 
-func (site *site) buildFile(path string) error {
+// Build the file at the given src path into the target directory, returning the details of
+// the written file, or nil if it was skipped.
+func (site *site) buildFile(path string) (*FileReport, error) {
 	subpath, _ := filepath.Rel(site.config.SrcDir, path)
-	targetPath := filepath.Join(site.config.TargetDir, subpath)
+	// the path of the output file, relative to the target directory
+	targetPath := subpath
+	file := &FileReport{Source: site.srcPath(path), Warnings: make([]string, 0)}
 
 	var contentReader io.Reader
 	var err error
@@ -333,29 +627,50 @@ func (site *site) buildFile(path string) error {
 		// if no template found at location, treat the file as static write its contents to target
 		if site.config.LinkStatic {
 			// dev optimization: link static files instead of copying them
-			abs, _ := filepath.Abs(path)
-			err = os.Symlink(abs, targetPath)
-			return checkFileError(err)
+			err = site.output.Link(path, targetPath)
+			if err != nil {
+				return nil, checkFileError(err)
+			}
+			if info, err := os.Stat(path); err == nil {
+				file.Size = info.Size()
+			}
+			file.Path = filepath.ToSlash(targetPath)
+			return file, nil
 		}
 
 		srcFile, err := os.Open(path)
 		if err != nil {
-			return checkFileError(err)
+			return nil, checkFileError(err)
 		}
 		defer srcFile.Close()
 		contentReader = srcFile
 	} else {
+		if !templ.IsPublished() {
+			fmt.Println("skipping unpublished", filepath.Join(site.config.TargetDir, targetPath))
+			return nil, nil
+		}
 		if templ.IsDraft() && !site.config.IncludeDrafts {
-			fmt.Println("skipping draft", targetPath)
-			return nil
+			fmt.Println("skipping draft", filepath.Join(site.config.TargetDir, targetPath))
+			return nil, nil
+		}
+		if collection := site.findCollection(subpath); collection != nil && !collection.Output {
+			return nil, nil
 		}
 
-		content, err := site.render(templ)
+		start := time.Now()
+		content, warnings, err := site.render(templ)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		file.RenderTime = milliseconds(time.Since(start))
+		file.Warnings = warnings
+		file.Template = true
+
+		// the template target path may not match its source location (e.g. collection permalinks)
+		targetPath = filepath.FromSlash(templ.Metadata["path"].(string))
+		if err := site.output.MkdirAll(filepath.Dir(targetPath)); err != nil {
+			return nil, err
 		}
-
-		targetPath = strings.TrimSuffix(targetPath, filepath.Ext(targetPath)) + templ.TargetExt()
 		contentReader = bytes.NewReader(content)
 	}
 	targetExt := filepath.Ext(targetPath)
@@ -364,55 +679,164 @@ func (site *site) buildFile(path string) error {
 	if targetExt == ".html" && filepath.Base(targetPath) != "index.html" {
 		targetDir := strings.TrimSuffix(targetPath, ".html")
 		targetPath = filepath.Join(targetDir, "index.html")
-		err = os.MkdirAll(targetDir, DIR_RWE_MODE)
+		err = site.output.MkdirAll(targetDir)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	// post process file acording to extension and config
 	contentReader, err = markup.Smartify(targetExt, contentReader)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	file.Smartified = markup.IsSmartifiable(targetExt)
 	contentReader, err = site.injectLiveReload(targetExt, contentReader)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if site.config.Minify {
 		contentReader = site.minifier.Minify(subpath, contentReader)
+		file.Minified = site.minifier.IsMinifiable(subpath)
+	}
+
+	var content []byte
+	if site.config.Compress && markup.IsCompressible(targetExt) {
+		// keep the final contents to write their compressed versions afterwards
+		if content, err = io.ReadAll(contentReader); err != nil {
+			return nil, err
+		}
+		contentReader = bytes.NewReader(content)
 	}
 
 	// write the file contents over to target
-	return writeToFile(targetPath, contentReader)
+	file.Path = filepath.ToSlash(targetPath)
+	file.Size, err = site.output.WriteFile(targetPath, contentReader)
+	if err != nil {
+		return nil, err
+	}
+	if content != nil && len(content) >= site.config.CompressMinSize {
+		if file.Compressed, err = site.writeCompressed(targetPath, content); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
 }
 
-func (site *site) render(templ *markup.Template) ([]byte, error) {
-	ctx := site.AsContext()
+// Write the compressed versions of the given file contents next to it, e.g. index.html.gz,
+// for servers that support precompressed files (like nginx gzip_static).
+// Versions that aren't smaller than the original are skipped.
+func (site *site) writeCompressed(targetPath string, content []byte) (bool, error) {
+	written := false
+	for _, encoding := range markup.ENCODINGS {
+		compressed, err := encoding.Compress(content)
+		if err != nil {
+			return false, err
+		}
+		if len(compressed) >= len(content) {
+			continue
+		}
+		if _, err := site.output.WriteFile(targetPath+encoding.Ext, bytes.NewReader(compressed)); err != nil {
+			return false, err
+		}
+		written = true
+	}
+	return written, nil
+}
 
-	ctx["page"] = templ.Metadata
-	content, err := templ.RenderWith(ctx, site.config.HighlightTheme)
+// Describe the given error found when building the file at path, extracting its location if available.
+func (site *site) newIssue(path string, err error) BuildIssue {
+	issue := BuildIssue{Source: site.srcPath(path), Message: err.Error()}
+
+	var templateErr interface {
+		Path() string
+		LineNumber() int
+	}
+	var frontMatterErr *markup.FrontMatterError
+	if errors.As(err, &frontMatterErr) {
+		issue.Line = frontMatterErr.Line
+	} else if errors.As(err, &templateErr) {
+		issue.Line = templateErr.LineNumber()
+		// the error may come from one of the template layouts
+		if errPath := templateErr.Path(); errPath != "" && errPath != path {
+			issue.File = site.srcPath(errPath)
+		}
+	}
+	return issue
+}
+
+// Return the path of the given src file relative to the project root, as used for `src_path`.
+func (site *site) srcPath(path string) string {
+	srcPath, err := filepath.Rel(site.config.RootDir, path)
 	if err != nil {
-		return nil, err
+		// the src dir may be configured outside the project, leave the path as is
+		return path
 	}
+	return srcPath
+}
+
+// Render the given template and its parent layouts, returning the resulting content
+// and the non-fatal problems found in the process (e.g. missing layouts or includes).
+func (site *site) render(templ *markup.Template) ([]byte, []string, error) {
+	// discard the warnings of previous renders of the template, e.g. for the post previews
+	srcPath, _ := templ.Metadata["src_path"].(string)
+	site.takeWarnings(srcPath)
+
+	ctx := site.AsContext()
+	ctx["page"] = templ.Metadata
+	content, err := templ.RenderWith(ctx, site.config.HighlightTheme)
 
 	// recursively render parent layouts
+	var missingLayout interface{}
 	layout := templ.Metadata["layout"]
 	for layout != nil && err == nil {
 		if layout_templ, ok := site.layouts[layout.(string)]; ok {
 			ctx["layout"] = layout_templ.Metadata
 			ctx["content"] = content
 			content, err = layout_templ.RenderWith(ctx, site.config.HighlightTheme)
-			if err != nil {
-				return nil, err
-			}
 			layout = layout_templ.Metadata["layout"]
 		} else {
-			return nil, fmt.Errorf("layout '%s' not found", layout)
+			// output the content rendered so far
+			missingLayout = layout
+			break
 		}
 	}
 
-	return content, nil
+	warnings := site.takeWarnings(srcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if missingLayout != nil {
+		warnings = append(warnings, fmt.Sprintf("layout '%s' not found", missingLayout))
+	}
+	return content, warnings, nil
+}
+
+// Record a problem found while rendering the page of the given render context.
+func (site *site) addRenderWarning(ctx map[string]interface{}, message string) {
+	page, _ := ctx["page"].(map[string]interface{})
+	srcPath, _ := page["src_path"].(string)
+	site.addWarning(srcPath, message)
+}
+
+// Record a problem found while processing the template with the given src_path.
+// Templates are rendered concurrently, so the warnings are kept by template until its render is done.
+func (site *site) addWarning(srcPath string, message string) {
+	site.warningsMutex.Lock()
+	defer site.warningsMutex.Unlock()
+	site.warnings[srcPath] = append(site.warnings[srcPath], message)
+}
+
+// Return the warnings recorded for the template with the given src_path, clearing them.
+func (site *site) takeWarnings(srcPath string) []string {
+	site.warningsMutex.Lock()
+	defer site.warningsMutex.Unlock()
+	warnings := site.warnings[srcPath]
+	delete(site.warnings, srcPath)
+	if warnings == nil {
+		warnings = make([]string, 0)
+	}
+	return warnings
 }
 
 func (site *site) AsContext() map[string]interface{} {
@@ -423,6 +847,7 @@ func (site *site) AsContext() map[string]interface{} {
 			"tags":         site.tags,
 			"pages":        site.pages,
 			"static_files": site.static_files,
+			"collections":  site.collections,
 			"data":         site.data,
 		},
 	}
@@ -441,20 +866,19 @@ func checkFileError(err error) error {
 	return err
 }
 
-func writeToFile(targetPath string, source io.Reader) error {
+// Write the contents of source to the given path, returning the number of bytes written.
+func writeToFile(targetPath string, source io.Reader) (int64, error) {
 	targetFile, err := os.Create(targetPath)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer targetFile.Close()
 
-	_, err = io.Copy(targetFile, source)
+	size, err := io.Copy(targetFile, source)
 	if err != nil {
-		return err
+		return 0, err
 	}
-
-	fmt.Println("wrote", targetPath)
-	return targetFile.Sync()
+	return size, targetFile.Sync()
 }
 
 // Assuming the given template is a post, try to generating a preview version of its context
@@ -479,7 +903,10 @@ func getPreviewContent(templ *markup.Template) (string, string) {
 	return string(content), excerpt
 }
 
-// if live reload is enabled, inject the reload snippet to html files
+// if live reload is enabled, inject the reload snippet to html files.
+// The snippet swaps the stylesheets in place on css events, reloads the page on page and full
+// events (restoring the scroll position on the former), and shows an overlay with the
+// problems found on build-error events, until the next successful rebuild.
 func (site *site) injectLiveReload(extension string, contentReader io.Reader) (io.Reader, error) {
 	if !site.config.LiveReload || extension != ".html" {
 		return contentReader, nil
@@ -487,13 +914,89 @@ func (site *site) injectLiveReload(extension string, contentReader io.Reader) (i
 
 	const JS_SNIPPET = `
 const url = location.origin + '/_events/'
+const overlayId = '_jorge-build-errors';
 var eventSource;
+function hideBuildErrors() {
+  const overlay = document.getElementById(overlayId);
+  if (overlay) {
+    overlay.remove();
+  }
+}
+function showBuildErrors(errors) {
+  hideBuildErrors();
+  const overlay = document.createElement('div');
+  overlay.id = overlayId;
+  overlay.title = 'click to dismiss';
+  overlay.style.cssText = 'position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:2em;' +
+    'background:rgba(20,20,20,.92);color:#eee;font:14px/1.5 monospace;cursor:pointer;';
+  overlay.onclick = hideBuildErrors;
+
+  const title = document.createElement('h2');
+  title.style.cssText = 'color:#ff6b6b;font:bold 18px monospace;margin:0 0 1em;';
+  title.textContent = 'build failed with ' + errors.length + (errors.length == 1 ? ' error' : ' errors');
+  overlay.appendChild(title);
+  for (const error of errors) {
+    let location = error.source || '';
+    if (error.file) {
+      location += ' (' + error.file + ')';
+    }
+    if (error.line) {
+      location += ':' + error.line;
+    }
+    const item = document.createElement('pre');
+    item.style.cssText = 'white-space:pre-wrap;margin:0 0 1.5em;';
+    const header = document.createElement('strong');
+    header.style.color = '#ffd479';
+    header.textContent = location;
+    item.append(header, location ? '\n' : '', error.message);
+    overlay.appendChild(item);
+  }
+  document.body.appendChild(overlay);
+}
+function reloadStylesheets() {
+  hideBuildErrors();
+  for (const link of document.querySelectorAll('link[rel="stylesheet"]')) {
+    const href = new URL(link.href);
+    if (href.origin !== location.origin) {
+      continue;
+    }
+    // load the new version before removing the old one, to prevent a flash of unstyled content
+    href.searchParams.set('_reload', Date.now());
+    const newLink = link.cloneNode();
+    newLink.href = href.href;
+    newLink.onload = newLink.onerror = function () {
+      link.remove();
+    };
+    link.after(newLink);
+  }
+}
+const scrollKey = '_jorge-scroll:' + location.pathname;
+function reloadPage(keepScroll) {
+  if (keepScroll) {
+    sessionStorage.setItem(scrollKey, JSON.stringify([window.scrollX, window.scrollY]));
+  }
+  location.reload();
+}
+const savedScroll = sessionStorage.getItem(scrollKey);
+if (savedScroll) {
+  sessionStorage.removeItem(scrollKey);
+  window.addEventListener('load', function () {
+    window.scrollTo(...JSON.parse(savedScroll));
+  });
+}
 function newSSE() {
   console.log("connecting to server events");
   eventSource = new EventSource(url);
-  eventSource.onmessage = function () {
-    location.reload()
-  };
+  eventSource.addEventListener('css', reloadStylesheets);
+  eventSource.addEventListener('page', function () {
+    reloadPage(true);
+  });
+  eventSource.addEventListener('full', function () {
+    reloadPage(false);
+  });
+  eventSource.addEventListener('build-error', function (event) {
+    showBuildErrors(JSON.parse(event.data));
+  });
   window.onbeforeunload = function() {
     eventSource.close();
   }
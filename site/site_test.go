@@ -1,10 +1,15 @@
 package site
 
 import (
+	"compress/gzip"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/facundoolano/jorge/config"
 )
@@ -84,7 +89,7 @@ title: about
 	_, ok = site.layouts["post"]
 	assert(t, ok)
 
-	output, err := site.render(site.templates[helloPath])
+	output, _, err := site.render(site.templates[helloPath])
 	assertEqual(t, err, nil)
 	assertEqual(t, string(output), `<html>
 <head><title>hello world!</title></head>
@@ -95,7 +100,7 @@ title: about
 </body>
 </html>`)
 
-	output, err = site.render(site.templates[goodbyePath])
+	output, _, err = site.render(site.templates[goodbyePath])
 	assertEqual(t, err, nil)
 	assertEqual(t, string(output), `<html>
 <head><title>goodbye!</title></head>
@@ -106,7 +111,7 @@ title: about
 </body>
 </html>`)
 
-	output, err = site.render(site.templates[aboutPath])
+	output, _, err = site.render(site.templates[aboutPath])
 	assertEqual(t, err, nil)
 	assertEqual(t, string(output), `<html>
 <head><title>about</title></head>
@@ -262,7 +267,7 @@ date: 2023-01-01
 	defer os.Remove(file.Name())
 
 	site, _ := load(*config)
-	output, err := site.render(site.templates[file.Name()])
+	output, _, err := site.render(site.templates[file.Name()])
 	assertEqual(t, err, nil)
 	assertEqual(t, string(output), `<ul>
 <li>2024-02-01 <a href="/goodbye">goodbye!</a></li>
@@ -351,7 +356,7 @@ tags: [software]
 	defer os.Remove(file.Name())
 
 	site, _ := load(*config)
-	output, err := site.render(site.templates[file.Name()])
+	output, _, err := site.render(site.templates[file.Name()])
 	assertEqual(t, err, nil)
 	assertEqual(t, string(output), `<h1>software</h1>
 hello world!
@@ -402,7 +407,7 @@ title: "2. an oldie!"
 	defer os.Remove(file.Name())
 
 	site, _ := load(*config)
-	output, err := site.render(site.templates[file.Name()])
+	output, _, err := site.render(site.templates[file.Name()])
 	assertEqual(t, err, nil)
 	assertEqual(t, string(output), `<ul>
 <li><a href="/01-hello">1. hello world!</a></li>
@@ -457,7 +462,7 @@ tags: [software]
 	defer os.Remove(file.Name())
 
 	site, _ := load(*config)
-	output, err := site.render(site.templates[file.Name()])
+	output, _, err := site.render(site.templates[file.Name()])
 	assertEqual(t, err, nil)
 	assertEqual(t, strings.TrimSpace(string(output)), `goodbye! - an overridden excerpt
 
@@ -504,7 +509,7 @@ excerpt: an overridden excerpt
 	defer os.Remove(file.Name())
 
 	site, _ := load(*config)
-	output, err := site.render(site.templates[file.Name()])
+	output, _, err := site.render(site.templates[file.Name()])
 	assertEqual(t, err, nil)
 	assertEqual(t, strings.TrimSpace(string(output)), `<h1>goodbye!</h1>
 <p>goodbye world!</p>
@@ -539,7 +544,7 @@ func TestRenderDataFile(t *testing.T) {
 	defer os.Remove(file.Name())
 
 	site, _ := load(*config)
-	output, err := site.render(site.templates[file.Name()])
+	output, _, err := site.render(site.templates[file.Name()])
 	assertEqual(t, err, nil)
 	assertEqual(t, string(output), `<ul>
 <li><a href="https://github.com/facundoolano/feedi">feedi</a></li>
@@ -547,6 +552,318 @@ func TestRenderDataFile(t *testing.T) {
 </ul>`)
 }
 
+func TestRenderDataFileFormats(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+
+	newFile(config.DataDir, "projects.json", `[{"name": "feedi"}, {"name": "jorge"}]`)
+	newFile(config.DataDir, "settings.toml", `title = "my site"`)
+	newFile(config.DataDir, "notes.txt", `ignored`)
+	newFile(config.DataDir, "empty.yml", ``)
+	newFile(config.DataDir, "authors", `- facundo`)
+
+	teamDir := filepath.Join(config.DataDir, "team")
+	os.Mkdir(teamDir, DIR_RWE_MODE)
+	newFile(teamDir, "members.csv", `name,role
+"Olano, Facundo",author
+jorge,generator`)
+	newFile(teamDir, "roles.tsv", "role\tdescription\nauthor\twrites posts")
+
+	content := `---
+---
+{{ site.data.settings.title }}
+{% for project in site.data.projects %}{{project.name}} {% endfor %}
+{% for member in site.data.team.members %}{{member.name}}: {{member.role}}. {% endfor %}
+{{ site.data.team.roles[0].description }}
+{{ site.data.authors[0] }}`
+	file := newFile(config.SrcDir, "about.html", content)
+
+	site, err := load(*config)
+	assertEqual(t, err, nil)
+	_, found := site.data["notes"]
+	assert(t, !found)
+	// empty files are still loaded
+	empty, found := site.data["empty"]
+	assert(t, found)
+	assertEqual(t, empty, nil)
+
+	output, _, err := site.render(site.templates[file.Name()])
+	assertEqual(t, err, nil)
+	assertEqual(t, string(output), `my site
+feedi jorge 
+Olano, Facundo: author. jorge: generator. 
+writes posts
+facundo`)
+
+	// a file can't have the same name as a data directory
+	newFile(config.DataDir, "team.yml", `- jorge`)
+	_, err = load(*config)
+	assert(t, strings.Contains(err.Error(), "conflicts"))
+}
+
+func TestCollections(t *testing.T) {
+	projectConfig := newProject()
+	defer os.RemoveAll(projectConfig.RootDir)
+
+	newFile(projectConfig.RootDir, "config.yml", `
+collections:
+  projects:
+    dir: projects
+    sort_by: title
+    layout: base
+    permalink: /work/:name/
+  talks:
+    glob: "talks/**/*.md"
+    sort_by: date
+    reverse: true
+    output: false
+`)
+	projectConfig, err := config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+	projectConfig.Minify = false
+
+	newFile(projectConfig.LayoutsDir, "base.html", `---
+---
+<div>{{content}}</div>`)
+
+	projectsDir := filepath.Join(projectConfig.SrcDir, "projects")
+	talksDir := filepath.Join(projectConfig.SrcDir, "talks", "2024")
+	os.Mkdir(projectsDir, DIR_RWE_MODE)
+	os.MkdirAll(talksDir, DIR_RWE_MODE)
+
+	newFile(projectsDir, "jorge.html", `---
+title: jorge
+date: 2024-01-01
+tags: [go]
+---
+<p>a site generator</p>`)
+	newFile(projectsDir, "feedi.html", `---
+title: feedi
+---
+<p>a feed reader</p>`)
+	newFile(talksDir, "first.md", `---
+title: first talk
+date: 2024-01-01
+tags: [go]
+---
+hello`)
+	newFile(talksDir, "second.md", `---
+title: second talk
+date: 2024-02-01
+---
+bye`)
+	file := newFile(projectConfig.SrcDir, "index.html", `---
+---
+{% for project in site.collections.projects %}{{project.title}} {{project.url}} {% endfor %}
+{% for talk in site.collections.talks %}{{talk.title}} {% endfor %}
+{{ site.posts | size }} {{ site.pages | size }}
+{% for item in site.tags.go %}{{item.title}}{% endfor %}`)
+
+	site, err := load(*projectConfig)
+	assertEqual(t, err, nil)
+	output, _, err := site.render(site.templates[file.Name()])
+	assertEqual(t, err, nil)
+	assertEqual(t, string(output), `feedi /work/feedi jorge /work/jorge 
+second talk first talk 
+0 0
+jorge`)
+
+	// prev and next are populated within the collection
+	feedi := site.templates[filepath.Join(projectsDir, "feedi.html")]
+	assertEqual(t, feedi.Metadata["next"].(map[string]interface{})["title"], "jorge")
+	first := site.templates[filepath.Join(talksDir, "first.md")]
+	assertEqual(t, first.Metadata["previous"].(map[string]interface{})["title"], "second talk")
+
+	err = site.build()
+	assertEqual(t, err, nil)
+
+	// the collection permalink and default layout are used for the output
+	output, err = os.ReadFile(filepath.Join(projectConfig.TargetDir, "work", "jorge", "index.html"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(output), `<html><head></head><body><div><p>a site generator</p></div></body></html>`)
+	_, err = os.Stat(filepath.Join(projectConfig.TargetDir, "projects", "jorge", "index.html"))
+	assert(t, os.IsNotExist(err))
+
+	// talks are not written to target
+	_, err = os.Stat(filepath.Join(projectConfig.TargetDir, "talks", "2024", "first", "index.html"))
+	assert(t, os.IsNotExist(err))
+}
+
+func TestCollectionPermalinkConflict(t *testing.T) {
+	projectConfig := newProject()
+	defer os.RemoveAll(projectConfig.RootDir)
+
+	newFile(projectConfig.RootDir, "config.yml", `
+collections:
+  projects:
+    dir: projects
+    permalink: /work/:name/
+`)
+	projectConfig, err := config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+
+	projectsDir := filepath.Join(projectConfig.SrcDir, "projects")
+	os.MkdirAll(filepath.Join(projectsDir, "old"), DIR_RWE_MODE)
+	newFile(projectsDir, "jorge.html", `---
+title: jorge
+---
+<p>a site generator</p>`)
+	newFile(filepath.Join(projectsDir, "old"), "jorge.html", `---
+title: old jorge
+---
+<p>a site generator</p>`)
+
+	_, err = load(*projectConfig)
+	assert(t, err != nil)
+	assertEqual(t, err.Error(), "collection items src/projects/jorge.html and src/projects/old/jorge.html have the same permalink /work/jorge")
+
+	// excluded items don't conflict
+	newFile(filepath.Join(projectsDir, "old"), "jorge.html", `---
+title: old jorge
+draft: true
+---
+<p>a site generator</p>`)
+	_, err = load(*projectConfig)
+	assertEqual(t, err, nil)
+}
+
+func TestCollectionPermalinkWithoutDate(t *testing.T) {
+	projectConfig := newProject()
+	defer os.RemoveAll(projectConfig.RootDir)
+
+	newFile(projectConfig.RootDir, "config.yml", `
+collections:
+  notes:
+    dir: notes
+    permalink: /:year/:name/
+`)
+	projectConfig, err := config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+
+	notesDir := filepath.Join(projectConfig.SrcDir, "notes")
+	os.Mkdir(notesDir, DIR_RWE_MODE)
+	newFile(notesDir, "dated.md", `---
+title: dated
+date: 2024-03-01
+---
+hello`)
+	site, err := load(*projectConfig)
+	assertEqual(t, err, nil)
+	assertEqual(t, site.templates[filepath.Join(notesDir, "dated.md")].Metadata["url"], "/2024/dated")
+
+	// undated items can't resolve the date placeholders
+	newFile(notesDir, "undated.md", `---
+title: undated
+---
+hello`)
+	_, err = load(*projectConfig)
+	assert(t, err != nil)
+	assertEqual(t, err.Error(), "invalid permalink for collection item src/notes/undated.md: the notes permalink /:year/:name/ requires a date")
+}
+
+func TestFrontMatterDefaults(t *testing.T) {
+	projectConfig := newProject()
+	defer os.RemoveAll(projectConfig.RootDir)
+
+	newFile(projectConfig.RootDir, "config.yml", `
+defaults:
+  - values:
+      lang: en
+  - path: blog
+    values:
+      layout: post
+      lang: es
+  - path: "blog/*.md"
+    values:
+      lang: fr
+`)
+	projectConfig, err := config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+
+	blogDir := filepath.Join(projectConfig.SrcDir, "blog")
+	os.Mkdir(blogDir, DIR_RWE_MODE)
+	newFile(blogDir, "hello.org", `---
+title: hello
+date: 2024-01-01
+---`)
+	newFile(blogDir, "goodbye.md", `---
+title: goodbye
+date: 2024-01-02
+---`)
+	newFile(blogDir, "hallo.org", `---
+title: hallo
+date: 2024-01-03
+lang: de
+layout: base
+---`)
+	newFile(projectConfig.SrcDir, "about.html", `---
+title: about
+---`)
+
+	// the effective values are visible in the site metadata
+	output, err := EvalMetadata(*projectConfig, "site.posts | map:'lang'")
+	assertEqual(t, err, nil)
+	assertEqual(t, output, `["de","fr","es"]`)
+
+	output, err = EvalMetadata(*projectConfig, "site.posts | map:'layout'")
+	assertEqual(t, err, nil)
+	assertEqual(t, output, `["base","post","post"]`)
+
+	output, err = EvalMetadata(*projectConfig, "site.pages | map:'lang'")
+	assertEqual(t, err, nil)
+	assertEqual(t, output, `["en"]`)
+}
+
+func TestFrontMatterDefaultsTypes(t *testing.T) {
+	projectConfig := newProject()
+	defer os.RemoveAll(projectConfig.RootDir)
+
+	// quoted dates are parsed like the ones in front matter
+	newFile(projectConfig.RootDir, "config.yml", `
+defaults:
+  - path: blog
+    values:
+      date: "2024-01-01"
+`)
+	projectConfig, err := config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+	blogDir := filepath.Join(projectConfig.SrcDir, "blog")
+	os.Mkdir(blogDir, DIR_RWE_MODE)
+	newFile(blogDir, "hello.md", `---
+title: hello
+---`)
+	newFile(blogDir, "goodbye.md", `---
+title: goodbye
+date: 2024-01-02
+---`)
+
+	output, err := EvalMetadata(*projectConfig, "site.posts | map:'title'")
+	assertEqual(t, err, nil)
+	assertEqual(t, output, `["goodbye","hello"]`)
+
+	// invalid values are reported instead of breaking the build
+	newFile(projectConfig.RootDir, "config.yml", `
+defaults:
+  - path: blog
+    values:
+      date: soon
+`)
+	projectConfig, err = config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+	_, err = load(*projectConfig)
+	assertEqual(t, err.Error(), "invalid front matter defaults for "+filepath.Join("src", "blog", "hello.md")+": can't parse 'soon' as a date")
+
+	newFile(projectConfig.RootDir, "config.yml", `
+defaults:
+  - values:
+      draft: "yes"
+`)
+	projectConfig, err = config.Load(projectConfig.RootDir, "")
+	assertEqual(t, err, nil)
+	_, err = load(*projectConfig)
+	assert(t, strings.HasSuffix(err.Error(), `'draft' expected true or false, got "yes"`))
+}
+
 func TestBuildTarget(t *testing.T) {
 	config := newProject()
 	defer os.RemoveAll(config.RootDir)
@@ -707,6 +1024,386 @@ layout: base
 </body></html>`)
 }
 
+func TestBuildWithCustomDirs(t *testing.T) {
+	projectDir, _ := os.MkdirTemp("", "root")
+	defer os.RemoveAll(projectDir)
+	sharedDir, _ := os.MkdirTemp("", "shared")
+	defer os.RemoveAll(sharedDir)
+
+	// keep the layouts outside of the project root and the sources in a custom dir
+	os.Mkdir(filepath.Join(projectDir, "site"), DIR_RWE_MODE)
+	newFile(projectDir, "config.yml", "source: site\nlayouts_dir: "+sharedDir+"\ndestination: out\n")
+	newFile(sharedDir, "base.html", `---
+---
+<html><body>{{content}}</body></html>`)
+	newFile(filepath.Join(projectDir, "site"), "hello.html", `---
+layout: base
+---
+<p>hello</p>`)
+
+	config, err := config.Load(projectDir, "")
+	assertEqual(t, err, nil)
+	config.Minify = false
+	assertEqual(t, config.SrcDir, filepath.Join(projectDir, "site"))
+	assertEqual(t, config.LayoutsDir, sharedDir)
+	assertEqual(t, config.TargetDir, filepath.Join(projectDir, "out"))
+
+	err = Build(*config)
+	assertEqual(t, err, nil)
+	output, err := os.ReadFile(filepath.Join(projectDir, "out", "hello", "index.html"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(output), "<html><head></head><body><p>hello</p></body></html>")
+
+	// refuse to build into a directory that would delete the sources
+	for _, target := range []string{projectDir, config.SrcDir, filepath.Join(config.SrcDir, "out")} {
+		config.TargetDir = target
+		err = Build(*config)
+		assert(t, err != nil)
+		assert(t, strings.Contains(err.Error(), "invalid destination directory"))
+	}
+	_, err = os.Stat(filepath.Join(config.SrcDir, "hello.html"))
+	assertEqual(t, err, nil)
+}
+
+func TestBuildWithExclusions(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+	config.Exclude = []string{"*.psd", "README.md", "notes/"}
+	config.Include = []string{".well-known"}
+	config.IncludeDrafts = true
+
+	os.Mkdir(filepath.Join(config.SrcDir, "notes"), DIR_RWE_MODE)
+	os.Mkdir(filepath.Join(config.SrcDir, ".well-known"), DIR_RWE_MODE)
+	newFile(config.SrcDir, "README.md", "# readme")
+	newFile(config.SrcDir, "cover.psd", "psd")
+	newFile(config.SrcDir, ".DS_Store", "")
+	newFile(filepath.Join(config.SrcDir, "notes"), "todo.html", "todo")
+	newFile(filepath.Join(config.SrcDir, ".well-known"), "security.txt", "contact")
+	newFile(config.SrcDir, "draft.html", `---
+title: draft
+date: 2024-01-01
+draft: true
+---
+draft`)
+	newFile(config.SrcDir, "unpublished.html", `---
+title: unpublished
+date: 2024-01-02
+published: false
+---
+unpublished`)
+	newFile(config.SrcDir, "index.html", `---
+---
+{% for post in site.posts %}{{ post.title }} {% endfor %}`)
+
+	site, err := load(*config)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(site.posts), 1)
+	assertEqual(t, site.posts[0]["title"], "draft")
+	assertEqual(t, len(site.static_files), 1)
+	assertEqual(t, site.static_files[0]["path"], filepath.Join(".well-known", "security.txt"))
+
+	err = site.build()
+	assertEqual(t, err, nil)
+
+	output, _ := os.ReadFile(filepath.Join(config.TargetDir, "index.html"))
+	assertEqual(t, string(output), "<html><head></head><body>draft </body></html>")
+	for _, path := range []string{"README.md", "README", "cover.psd", ".DS_Store", "notes", "unpublished"} {
+		_, err = os.Stat(filepath.Join(config.TargetDir, path))
+		assert(t, os.IsNotExist(err))
+	}
+	for _, path := range []string{"draft/index.html", ".well-known/security.txt"} {
+		_, err = os.Stat(filepath.Join(config.TargetDir, path))
+		assertEqual(t, err, nil)
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+	config.Minify = true
+
+	newFile(config.SrcDir, "index.html", `---
+title: home
+---
+<p>"hello"</p>`)
+	newFile(config.SrcDir, "style.css", "body {  color: red;  }")
+	config.KeepGoing = true
+	report, err := BuildWithReport(*config)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(report.Files), 2)
+	assertEqual(t, len(report.Errors), 0)
+
+	index := report.Files[0]
+	assertEqual(t, index.Path, "index.html")
+	assertEqual(t, index.Source, filepath.Join("src", "index.html"))
+	assertEqual(t, index.Template, true)
+	assertEqual(t, index.Minified, true)
+	assertEqual(t, index.Smartified, true)
+	output, _ := os.ReadFile(filepath.Join(config.TargetDir, "index.html"))
+	assertEqual(t, index.Size, int64(len(output)))
+
+	style := report.Files[1]
+	assertEqual(t, style.Path, "style.css")
+	assertEqual(t, style.Template, false)
+	assertEqual(t, style.RenderTime, 0.0)
+	assertEqual(t, style.Minified, true)
+	assertEqual(t, style.Smartified, false)
+	assertEqual(t, style.Size, int64(len("body{color:red}")))
+
+	reportPath := filepath.Join(config.RootDir, "reports", "build.json")
+	err = report.WriteJSON(reportPath)
+	assertEqual(t, err, nil)
+	content, _ := os.ReadFile(reportPath)
+	assert(t, strings.Contains(string(content), `"path": "style.css"`))
+}
+
+func TestBuildCompressed(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+	config.Compress = true
+	config.CompressMinSize = 100
+
+	page := strings.Repeat("<p>hello world</p>\n", 20)
+	newFile(config.SrcDir, "index.html", "---\n---\n"+page)
+	newFile(config.SrcDir, "small.css", "body {}")
+	newFile(config.SrcDir, "data.bin", strings.Repeat("x", 200))
+
+	report, err := BuildWithReport(*config)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(report.Files), 3)
+	for _, file := range report.Files {
+		assertEqual(t, file.Compressed, file.Path == "index.html")
+	}
+
+	content, _ := os.ReadFile(filepath.Join(config.TargetDir, "index.html"))
+	compressed, err := os.Open(filepath.Join(config.TargetDir, "index.html.gz"))
+	assertEqual(t, err, nil)
+	defer compressed.Close()
+	reader, err := gzip.NewReader(compressed)
+	assertEqual(t, err, nil)
+	decompressed, _ := io.ReadAll(reader)
+	assertEqual(t, string(decompressed), string(content))
+
+	_, err = os.Stat(filepath.Join(config.TargetDir, "index.html.br"))
+	assertEqual(t, err, nil)
+	_, err = os.Stat(filepath.Join(config.TargetDir, "small.css.gz"))
+	assert(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(config.TargetDir, "data.bin.gz"))
+	assert(t, os.IsNotExist(err))
+}
+
+func TestBuildErrorsAndWarnings(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+
+	newFile(config.LayoutsDir, "base.html", `---
+---
+<html><body>
+{% include two args %}
+{{ content }}
+</body></html>`)
+	newFile(config.SrcDir, "index.html", `---
+---
+<p>hello</p>`)
+	newFile(config.SrcDir, "broken.html", `---
+title: broken
+---
+<p>hello</p>
+{% include two args %}`)
+	newFile(config.SrcDir, "broken-layout.html", `---
+layout: base
+---
+<p>hello</p>`)
+	newFile(config.SrcDir, "missing.html", `---
+layout: missing
+---
+<p>{% include missing.html %}missing</p>`)
+
+	// render errors fail the build, leaving the previous target untouched
+	os.Mkdir(config.TargetDir, DIR_RWE_MODE)
+	newFile(config.TargetDir, "previous.html", "previous")
+	report, err := BuildWithReport(*config)
+	assertEqual(t, err.Error(), "build failed with 2 errors")
+	assertEqual(t, len(report.Files), 2)
+	_, err = os.Stat(filepath.Join(config.TargetDir, "index.html"))
+	assert(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(config.TargetDir, "previous.html"))
+	assertEqual(t, err, nil)
+
+	slices.SortFunc(report.Errors, func(a BuildIssue, b BuildIssue) int {
+		return strings.Compare(a.Source, b.Source)
+	})
+	assertEqual(t, report.Errors[0].Location(), filepath.Join("src", "broken-layout.html")+" ("+filepath.Join("layouts", "base.html")+"):4")
+	assertEqual(t, report.Errors[1].Location(), filepath.Join("src", "broken.html")+":5")
+
+	// missing layouts and includes are warnings
+	warnings := report.Warnings()
+	assertEqual(t, len(warnings), 2)
+	assertEqual(t, warnings[0].Source, filepath.Join("src", "missing.html"))
+	assertEqual(t, warnings[0].Message, "include 'missing.html' not found")
+	assertEqual(t, warnings[1].Message, "layout 'missing' not found")
+
+	// when keeping going, the files that rendered replace the target
+	config.KeepGoing = true
+	_, err = BuildWithReport(*config)
+	assertEqual(t, err, nil)
+	output, _ := os.ReadFile(filepath.Join(config.TargetDir, "missing", "index.html"))
+	assertEqual(t, string(output), "<html><head></head><body><p>missing</p></body></html>")
+	_, err = os.Stat(filepath.Join(config.TargetDir, "previous.html"))
+	assert(t, os.IsNotExist(err))
+
+	config.FailOnWarning = true
+	_, err = BuildWithReport(*config)
+	assertEqual(t, err.Error(), "build failed with 2 warnings")
+
+	// no staging dirs are left behind
+	entries, _ := os.ReadDir(config.RootDir)
+	for _, entry := range entries {
+		assert(t, !strings.Contains(entry.Name(), "staging"))
+	}
+}
+
+func TestBuildPostWarnings(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+
+	// posts are rendered once for their preview and once more for their output
+	newFile(config.SrcDir, "post.md", `---
+title: a post
+date: 2024-01-01
+---
+hello {% include missing.html %}`)
+
+	report, err := BuildWithReport(*config)
+	assertEqual(t, err, nil)
+	warnings := report.Warnings()
+	assertEqual(t, len(warnings), 1)
+	assertEqual(t, warnings[0].Source, filepath.Join("src", "post.md"))
+	assertEqual(t, warnings[0].Message, "include 'missing.html' not found")
+
+	config.FailOnWarning = true
+	_, err = BuildWithReport(*config)
+	assertEqual(t, err.Error(), "build failed with 1 warning")
+}
+
+func TestBuildInMemory(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+	config.LinkStatic = true
+
+	newFile(config.SrcDir, "index.html", `---
+---
+<p>home</p>`)
+	os.Mkdir(filepath.Join(config.SrcDir, "blog"), DIR_RWE_MODE)
+	newFile(config.SrcDir, "blog/hello.html", `---
+---
+<p>hello</p>`)
+	os.MkdirAll(filepath.Join(config.SrcDir, "assets", "css"), DIR_RWE_MODE)
+	newFile(config.SrcDir, "assets/css/main.css", `body {}`)
+
+	report, memFS, err := BuildInMemory(*config, false)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(report.Files), 3)
+	err = fstest.TestFS(memFS, "index.html", "blog/hello/index.html", "assets/css/main.css")
+	assertEqual(t, err, nil)
+
+	content, err := fs.ReadFile(memFS, "blog/hello/index.html")
+	assertEqual(t, err, nil)
+	assert(t, strings.Contains(string(content), "<p>hello</p>"))
+	// static files are read from the source
+	content, err = fs.ReadFile(memFS, "assets/css/main.css")
+	assertEqual(t, err, nil)
+	assertEqual(t, string(content), "body {}")
+
+	// nothing is written to the target
+	_, err = os.Stat(config.TargetDir)
+	assert(t, os.IsNotExist(err))
+
+	// unless requested
+	_, memFS, err = BuildInMemory(*config, true)
+	assertEqual(t, err, nil)
+	content, err = os.ReadFile(filepath.Join(config.TargetDir, "blog", "hello", "index.html"))
+	assertEqual(t, err, nil)
+	assert(t, strings.Contains(string(content), "<p>hello</p>"))
+	content, err = fs.ReadFile(memFS, "index.html")
+	assertEqual(t, err, nil)
+	assert(t, strings.Contains(string(content), "<p>home</p>"))
+}
+
+func TestInMemoryRedirects(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+	config.Redirects = map[string]string{"/feed/": "/blog/feed.xml", "/talks": "https://talks.example.com/"}
+
+	newFile(config.SrcDir, "about.html", `---
+redirect_from: [/me, /bio.html]
+---
+<p>about</p>`)
+
+	report, memFS, err := BuildInMemory(*config, false)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(report.Files), 1)
+
+	to, found := memFS.Redirect("/me/")
+	assert(t, found)
+	assertEqual(t, to, "/about")
+	to, found = memFS.Redirect("/bio.html")
+	assert(t, found)
+	assertEqual(t, to, "/about")
+	to, found = memFS.Redirect("/talks")
+	assert(t, found)
+	assertEqual(t, to, "https://talks.example.com/")
+	_, found = memFS.Redirect("/about")
+	assert(t, !found)
+
+	// redirects are only honored by the development server, production builds don't write them
+	err = Build(*config)
+	assertEqual(t, err, nil)
+	_, err = os.Stat(filepath.Join(config.TargetDir, "me"))
+	assert(t, os.IsNotExist(err))
+
+	// and they can't be declared twice
+	config.Redirects["/me"] = "/other"
+	_, _, err = BuildInMemory(*config, false)
+	assertEqual(t, err.Error(), "redirect from /me declared both in config.yml and src/about.html")
+}
+
+func TestListPages(t *testing.T) {
+	config := newProject()
+	defer os.RemoveAll(config.RootDir)
+	config.IncludeDrafts = false
+
+	os.MkdirAll(filepath.Join(config.SrcDir, "blog"), DIR_RWE_MODE)
+	newFile(config.SrcDir, "blog/draft.md", `---
+title: Draft
+date: 2024-01-01
+draft: true
+---
+draft`)
+	newFile(config.SrcDir, "blog/hidden.md", `---
+published: false
+---
+hidden`)
+	newFile(config.SrcDir, "about.html", `---
+---
+about`)
+	newFile(config.SrcDir, "style.css", "body {}")
+
+	pages, err := ListPages(*config)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(pages), 2)
+	assertEqual(t, pages[0].Source, filepath.Join("src", "about.html"))
+	assertEqual(t, pages[0].Url, "/about")
+	assertEqual(t, pages[0].Draft, false)
+	assert(t, pages[0].Date.IsZero())
+	assert(t, !pages[0].Modified.IsZero())
+	// drafts are listed even if excluded from the site
+	assertEqual(t, pages[1].Title, "Draft")
+	assertEqual(t, pages[1].Url, "/blog/draft")
+	assertEqual(t, pages[1].Draft, true)
+	assertEqual(t, pages[1].Date.Year(), 2024)
+}
+
 // ------ HELPERS --------
 
 func newProject() *config.Config {
@@ -718,7 +1415,7 @@ func newProject() *config.Config {
 	os.Mkdir(srcDir, DIR_RWE_MODE)
 	os.Mkdir(dataDir, DIR_RWE_MODE)
 
-	config, _ := config.Load(projectDir)
+	config, _ := config.Load(projectDir, "")
 	config.Minify = false
 
 	return config
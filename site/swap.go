@@ -0,0 +1,31 @@
+package site
+
+import (
+	"errors"
+	"os"
+)
+
+// Replace the target directory with the staging one, leaving the previous target contents
+// at the staging path. If the target doesn't exist, the staging directory is just renamed.
+func swapDirs(staging string, target string) error {
+	if _, err := os.Lstat(target); errors.Is(err, os.ErrNotExist) {
+		return os.Rename(staging, target)
+	}
+
+	if err := exchangeDirs(staging, target); err == nil {
+		return nil
+	}
+
+	// the platform or file system doesn't support exchanging paths atomically,
+	// fallback to moving the target out of the way first
+	previous := staging + "-previous"
+	if err := os.Rename(target, previous); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, target); err != nil {
+		// restore the previous target
+		os.Rename(previous, target)
+		return err
+	}
+	return os.Rename(previous, staging)
+}
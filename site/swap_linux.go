@@ -0,0 +1,8 @@
+package site
+
+import "golang.org/x/sys/unix"
+
+// Atomically exchange the given paths, so there's no moment where the target is missing.
+func exchangeDirs(a string, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
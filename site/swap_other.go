@@ -0,0 +1,10 @@
+//go:build !linux
+
+package site
+
+import "errors"
+
+// Exchanging paths atomically is only supported on linux.
+func exchangeDirs(a string, b string) error {
+	return errors.ErrUnsupported
+}